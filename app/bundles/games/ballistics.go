@@ -5,14 +5,15 @@ import (
 	"math"
 
 	"github.com/Tarliton/collision2d"
-	"github.com/krishamoud/game/app/common/db"
+	"github.com/krishamoud/game/app/common/protocol"
+	"github.com/krishamoud/game/app/common/spatial"
 	"github.com/krishamoud/game/app/common/utils"
 )
 
 // Ballistic is the base projectile class
 type Ballistic struct {
-	ID       string       `json:"id"`
-	PlayerID string       `json:"playerId"`
+	ID       uint32       `json:"id"`
+	PlayerID uint32       `json:"playerId"`
 	Speed    float64      `json:"speed"`
 	Point    *utils.Point `json:"point"`
 	Radius   float64      `json:"radius"`
@@ -22,11 +23,11 @@ type Ballistic struct {
 	circle   collision2d.Circle
 }
 
-// NewBallistic will generate a new ballistic from a player
-func NewBallistic(id string, speed, mass float64, point *utils.Point, deg float64, dist float64) *Ballistic {
+// NewBallistic will generate a new ballistic from a player. It gets an id
+// when pushed into the game. Speed is in units per second.
+func NewBallistic(id uint32, speed, mass float64, point *utils.Point, deg float64, dist float64) *Ballistic {
 	radius := utils.MassToRadius(mass) * 0.5
 	return &Ballistic{
-		ID:       db.RandomID(12),
 		PlayerID: id,
 		Speed:    speed,
 		Point:    point,
@@ -38,17 +39,41 @@ func NewBallistic(id string, speed, mass float64, point *utils.Point, deg float6
 	}
 }
 
-// Update moves the ballistic
-func (b *Ballistic) Update(g *Game) {
+// Update moves the ballistic dt seconds along
+func (b *Ballistic) Update(g *Game, dt float64) {
 	if b.Distance < 0 {
 		g.RemoveBallistic(b.ID)
 		return
 	}
-	deltaY := b.Speed * math.Sin(b.Degree)
-	deltaX := b.Speed * math.Cos(b.Degree)
+	deltaY := b.Speed * dt * math.Sin(b.Degree)
+	deltaX := b.Speed * dt * math.Cos(b.Degree)
 	b.Point.Y += deltaY
 	b.Point.X += deltaX
 	b.circle.Pos.X += deltaX
 	b.circle.Pos.Y += deltaY
 	b.Distance -= utils.GetHypotenuse(deltaX, deltaY)
 }
+
+// rect returns the box around the ballistic
+func (b *Ballistic) rect() spatial.Rect {
+	return spatial.Around(b.Point.X, b.Point.Y, b.Radius)
+}
+
+// State returns the ballistic as sent to clients
+func (b *Ballistic) State() protocol.Ballistic {
+	return protocol.Ballistic{
+		ID:       b.ID,
+		PlayerID: b.PlayerID,
+		Speed:    b.Speed,
+		Point:    protocol.Point{X: b.Point.X, Y: b.Point.Y},
+		Radius:   b.Radius,
+	}
+}
+
+func ballisticStates(ballistics []*Ballistic) []protocol.Ballistic {
+	states := make([]protocol.Ballistic, len(ballistics))
+	for i, b := range ballistics {
+		states[i] = b.State()
+	}
+	return states
+}
@@ -0,0 +1,125 @@
+// Package games handles everything related to our game
+package games
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Bots see the map through the same window as a 1080p client
+const (
+	botScreenWidth  = 1920
+	botScreenHeight = 1080
+)
+
+// bot is a player without a websocket that the game loop steers. It asks its
+// brain what to do every BotReactionTime ms instead of every tick, both to be
+// cheap and to give humans a chance. Filler bots come and go to keep the game
+// at cfg.Bots players; bots added with AddBot stay until they die.
+type bot struct {
+	player *Player
+	brain  BotBrain
+	filler bool
+	next   time.Time
+}
+
+// updateBots adds or removes filler bots so humans and filler bots together
+// make cfg.Bots, then lets every bot that is due think and act. Bots added
+// with AddBot don't count towards cfg.Bots.
+func (g *Game) updateBots() {
+	var fillers []uint32
+	g.bots.Each(func(b *bot) {
+		if b.filler {
+			fillers = append(fillers, b.player.ID)
+		}
+	})
+	humans := 0
+	g.Users.Each(func(p *Player) {
+		if p.Conn != nil {
+			humans++
+		}
+	})
+	want := g.cfg.Bots - humans
+	if want < 0 {
+		want = 0
+	}
+	for i := len(fillers); i < want; i++ {
+		g.addFiller()
+	}
+	for i := len(fillers) - 1; i >= want; i-- {
+		g.SpliceUser(fillers[i])
+	}
+	now := g.clock.Now()
+	g.bots.Each(func(b *bot) {
+		if now.Before(b.next) {
+			return
+		}
+		b.next = now.Add(time.Duration(g.cfg.BotReactionTime) * time.Millisecond)
+		b.act(g, b.brain.Think(b.view(g, now)))
+	})
+}
+
+// addFiller spawns a filler bot with the brain named by cfg.BotBrain, or a
+// seeker if there is no such brain
+func (g *Game) addFiller() {
+	brain, err := NewBrain(g.cfg.BotBrain)
+	if err != nil {
+		fmt.Println("[WARN] " + err.Error() + " " + g.cfg.BotBrain + ", using " + SeekerBrain)
+		brain, _ = NewBrain(SeekerBrain)
+	}
+	g.addBot(brain, true)
+}
+
+// AddBot spawns a bot somewhere random that brain plays until it dies. Like
+// everything that changes the game it must only be called from the game
+// loop, or before GameInterval starts it.
+func (g *Game) AddBot(brain BotBrain) *Player {
+	return g.addBot(brain, false)
+}
+
+func (g *Game) addBot(brain BotBrain, filler bool) *Player {
+	p := NewPlayer(g, player, nil)
+	p.Name = "Bot " + strconv.Itoa(int(p.ID))
+	p.ScreenWidth = botScreenWidth
+	p.ScreenHeight = botScreenHeight
+	g.spawn(p)
+	g.bots.Add(p.ID, &bot{player: p, brain: brain, filler: filler})
+	return p
+}
+
+// view returns what the bot's player can see now
+func (b *bot) view(g *Game, now time.Time) *View {
+	p := b.player
+	return &View{
+		Self:       p.State(),
+		Players:    playerStates(p.VisibleCells(g)),
+		Food:       foodStates(p.VisibleFood(g)),
+		Ballistics: ballisticStates(p.VisibleBallistics(g)),
+		Viruses:    virusStates(p.VisibleViruses(g)),
+		GameWidth:  g.cfg.GameWidth,
+		GameHeight: g.cfg.GameHeight,
+		Now:        now,
+		Rand:       g.rand,
+		food:       g.FoodIndex,
+	}
+}
+
+// act applies in to the bot's player through the same commands client
+// messages turn into
+func (b *bot) act(g *Game, in Input) {
+	p := b.player
+	(&TargetCommand{p, in.Target}).Apply(g)
+	if in.ChangeShape {
+		p.ChangeShape(g.clock.Now())
+	}
+	if in.Sprint {
+		(&SprintCommand{p}).Apply(g)
+	}
+	if in.Split {
+		(&SplitCommand{p}).Apply(g)
+	}
+	if in.Fire {
+		(&FireCommand{p}).Apply(g)
+	}
+}
@@ -0,0 +1,252 @@
+package games
+
+import (
+	"testing"
+
+	"github.com/Tarliton/collision2d"
+	"github.com/krishamoud/game/app/common/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBotPopulationSpec(t *testing.T) {
+	Convey("Given a game that wants 3 bots", t, func() {
+		s := newSim(1)
+		s.g.cfg.Bots = 3
+
+		Convey("When it ticks with nobody in it", func() {
+			s.run(1)
+			Convey("Then it should fill up with bots", func() {
+				So(s.g.bots.Len(), ShouldEqual, 3)
+				So(s.g.Users.Len(), ShouldEqual, 3)
+				So(s.g.BotCount(), ShouldEqual, 3)
+			})
+		})
+
+		Convey("When humans join", func() {
+			s.run(1)
+			s.join("first", circle, 500, 500)
+			s.join("second", square, 1500, 1500)
+			s.run(1)
+			Convey("Then bots should leave to make room", func() {
+				So(s.g.bots.Len(), ShouldEqual, 1)
+				So(s.g.Users.Len(), ShouldEqual, 3)
+			})
+
+			Convey("Then no bots should be left once there are enough humans", func() {
+				s.join("third", circle, 1000, 500)
+				s.join("fourth", circle, 1000, 1500)
+				s.run(1)
+				So(s.g.bots.Len(), ShouldEqual, 0)
+				So(s.g.Users.Len(), ShouldEqual, 4)
+			})
+		})
+
+		Convey("When a bot is added by hand", func() {
+			brain, _ := NewBrain(GrazerBrain)
+			s.g.AddBot(brain)
+			s.run(1)
+			Convey("Then it should not take the place of a filler bot", func() {
+				So(s.g.bots.Len(), ShouldEqual, 4)
+				So(s.g.Users.Len(), ShouldEqual, 4)
+			})
+		})
+
+		Convey("When a bot dies", func() {
+			s.run(1)
+			_, b, _ := s.g.bots.Last()
+			b.player.MassCurrent = -1
+			s.run(2)
+			Convey("Then another should take its place on the next tick", func() {
+				So(s.alive(b.player), ShouldBeFalse)
+				So(s.g.bots.Len(), ShouldEqual, 3)
+			})
+		})
+
+		Convey("When a bot ticks", func() {
+			s.run(1)
+			_, b, _ := s.g.bots.Last()
+			Convey("Then it should be left out of state updates", func() {
+				So(func() { s.g.SendUpdates() }, ShouldNotPanic)
+				So(func() { b.player.KillMessage() }, ShouldNotPanic)
+			})
+		})
+	})
+}
+
+func TestSeekerSpec(t *testing.T) {
+	Convey("Given a seeker in the middle of the map", t, func() {
+		s := newSim(1)
+		brain, _ := NewBrain(SeekerBrain)
+		b := s.bot(brain, 1000, 1000)
+		p := b.player
+
+		Convey("When a bigger player is close by on its left", func() {
+			big := s.join("big", circle, 900, 1000)
+			big.MassTotal, big.MassCurrent = 200, 200
+			p.MassTotal, p.MassCurrent, p.Cells[0].Mass = 100, 100, 100
+			s.think(b)
+			Convey("Then it should run right and sprint", func() {
+				So(p.Target.X, ShouldBeGreaterThan, 0)
+				So(p.Target.Y, ShouldEqual, 0)
+				So(p.sprinting, ShouldBeTrue)
+			})
+		})
+
+		Convey("When a smaller player is on its right", func() {
+			small := s.join("small", circle, 1100, 1000)
+			small.MassTotal, small.MassCurrent = 10, 10
+			s.think(b)
+			Convey("Then it should chase and shoot at it", func() {
+				So(p.Target.X, ShouldBeGreaterThan, 0)
+				So(p.Target.Y, ShouldEqual, 0)
+				So(p.ShotsLeft, ShouldEqual, p.ClipSize-1)
+				So(s.g.Ballistics.Len(), ShouldBeGreaterThan, 0)
+			})
+		})
+
+		Convey("When the nearest food is out of sight", func() {
+			radius := utils.MassToRadius(s.g.cfg.FoodMass)
+			s.g.PushFood(&Food{
+				Point:  &utils.Point{X: 1800, Y: 1000},
+				Radius: radius,
+				Mass:   s.g.cfg.FoodMass,
+				Col:    collision2d.NewCircle(collision2d.NewVector(1800, 1000), radius),
+			})
+			s.think(b)
+			Convey("Then it should still head for it", func() {
+				So(p.Target.X, ShouldEqual, 800)
+				So(p.Target.Y, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the only thing around is food above it", func() {
+			radius := utils.MassToRadius(s.g.cfg.FoodMass)
+			s.g.PushFood(&Food{
+				Point:  &utils.Point{X: 1000, Y: 950},
+				Radius: radius,
+				Mass:   s.g.cfg.FoodMass,
+				Col:    collision2d.NewCircle(collision2d.NewVector(1000, 950), radius),
+			})
+			s.think(b)
+			Convey("Then it should head for the food", func() {
+				So(p.Target.X, ShouldEqual, 0)
+				So(p.Target.Y, ShouldEqual, -50)
+			})
+		})
+
+		Convey("When it is steered elsewhere before its reaction time is up", func() {
+			s.run(1)
+			before := *p.Target
+			s.target(1, p, 0, 0)
+			s.run(1)
+			Convey("Then it should not change its mind until the time is up", func() {
+				So(*p.Target, ShouldResemble, utils.Point{})
+				So(before, ShouldNotResemble, utils.Point{})
+			})
+		})
+	})
+
+	Convey("Given a grazer next to a smaller player", t, func() {
+		s := newSim(1)
+		brain, _ := NewBrain(GrazerBrain)
+		b := s.bot(brain, 1000, 1000)
+		small := s.join("small", circle, 1100, 1000)
+		small.MassTotal, small.MassCurrent = 10, 10
+
+		Convey("When it thinks", func() {
+			s.think(b)
+			Convey("Then it should leave the player alone", func() {
+				So(b.player.ShotsLeft, ShouldEqual, b.player.ClipSize)
+				So(s.g.Ballistics.Len(), ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestBrainSpec(t *testing.T) {
+	Convey("Given a bot played by a brain that records what it sees", t, func() {
+		s := newSim(1)
+		var seen *View
+		input := Input{Target: utils.Point{X: 100, Y: 0}, Fire: true, ChangeShape: true}
+		b := s.bot(BrainFunc(func(v *View) Input {
+			seen = v
+			return input
+		}), 1000, 1000)
+		p := b.player
+		other := s.join("other", square, 1050, 1000)
+
+		Convey("When it thinks", func() {
+			s.think(b)
+			Convey("Then it should have seen what a client would", func() {
+				So(seen.Self.ID, ShouldEqual, p.ID)
+				So(len(seen.Players), ShouldEqual, len(p.VisibleCells(s.g)))
+				So(seen.GameWidth, ShouldEqual, s.g.cfg.GameWidth)
+				var ids []uint32
+				for _, u := range seen.Players {
+					ids = append(ids, u.ID)
+				}
+				So(ids, ShouldContain, other.ID)
+			})
+			Convey("Then changing what it saw should not change the game", func() {
+				seen.Self.MassTotal = 1e6
+				seen.Self.Cells[0].Mass = 1e6
+				for i := range seen.Players {
+					seen.Players[i].Point.X = 0
+				}
+				So(p.MassTotal, ShouldNotEqual, 1e6)
+				So(p.Cells[0].Mass, ShouldNotEqual, 1e6)
+				So(other.Point.X, ShouldEqual, 1050)
+			})
+			Convey("Then its input should have been applied", func() {
+				So(*p.Target, ShouldResemble, input.Target)
+				So(p.Shape, ShouldEqual, square)
+				So(p.ShotsLeft, ShouldEqual, p.ClipSize-1)
+			})
+		})
+
+		Convey("When the game ticks with no room for filler bots", func() {
+			s.run(1)
+			Convey("Then it should stay, unlike a filler", func() {
+				So(s.alive(p), ShouldBeTrue)
+				So(seen, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given a registered brain", t, func() {
+		RegisterBrain("test", func() BotBrain {
+			return BrainFunc(func(v *View) Input { return Input{Sprint: true} })
+		})
+
+		Convey("Then NewBrain and Brains should know it", func() {
+			brain, err := NewBrain("test")
+			So(err, ShouldBeNil)
+			So(brain.Think(&View{}), ShouldResemble, Input{Sprint: true})
+			So(Brains(), ShouldContain, "test")
+			So(Brains(), ShouldContain, SeekerBrain)
+		})
+
+		Convey("Then filler bots should use it when the config names it", func() {
+			s := newSim(1)
+			s.g.cfg.Bots, s.g.cfg.BotBrain = 1, "test"
+			s.run(1)
+			_, b, _ := s.g.bots.Last()
+			So(b.brain.Think(&View{}), ShouldResemble, Input{Sprint: true})
+		})
+
+		Convey("Then filler bots should be seekers when the config names no brain", func() {
+			s := newSim(1)
+			s.g.cfg.Bots, s.g.cfg.BotBrain = 1, "nobody"
+			s.run(1)
+			_, b, _ := s.g.bots.Last()
+			So(b.brain, ShouldHaveSameTypeAs, &seeker{})
+		})
+	})
+
+	Convey("Given a name nobody registered", t, func() {
+		_, err := NewBrain("nobody")
+		Convey("Then NewBrain should fail", func() {
+			So(err, ShouldEqual, ErrUnknownBrain)
+		})
+	})
+}
@@ -0,0 +1,174 @@
+// Package games handles everything related to our game
+package games
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/krishamoud/game/app/common/protocol"
+	"github.com/krishamoud/game/app/common/spatial"
+	"github.com/krishamoud/game/app/common/utils"
+)
+
+// Names of the brains every server has
+const (
+	// SeekerBrain runs from bigger players, hunts smaller ones and eats
+	SeekerBrain = "seeker"
+	// GrazerBrain runs from bigger players and otherwise only eats
+	GrazerBrain = "grazer"
+)
+
+// ErrUnknownBrain is returned by NewBrain for names nobody registered
+var ErrUnknownBrain = errors.New("unknown bot brain")
+
+// BotBrain decides what a bot does. The game loop asks it every
+// BotReactionTime ms, so a brain only needs to be safe for concurrent use if
+// it is shared between bots in different games.
+type BotBrain interface {
+	Think(v *View) Input
+}
+
+// BrainFunc lets an ordinary function be a BotBrain
+type BrainFunc func(v *View) Input
+
+// Think calls f
+func (f BrainFunc) Think(v *View) Input {
+	return f(v)
+}
+
+// View is what a bot can see when it thinks, the same things a client is
+// sent in its state updates, so Players has the bot in it with an ID of 0.
+// It is all copies of the game state; a brain can only change the game
+// through the Input it returns. Rand is the game's random source; brains
+// that draw from it instead of their own play out the same way every time
+// in a seeded game.
+type View struct {
+	Self       protocol.Player
+	Players    []protocol.Player
+	Food       []protocol.Food
+	Ballistics []protocol.Ballistic
+	Viruses    []protocol.Virus
+	GameWidth  float64
+	GameHeight float64
+	Now        time.Time
+	Rand       Rand
+	food       spatial.Index[*Food]
+}
+
+// NearestFood returns up to k of the food pellets closest to the bot, nearest
+// first, wherever they are on the map
+func (v *View) NearestFood(k int) []protocol.Food {
+	if v.food == nil {
+		return nil
+	}
+	return foodStates(v.food.Nearest(v.Self.Point.X, v.Self.Point.Y, k))
+}
+
+// Input is what a bot does after thinking, the same things a client can ask
+// for
+type Input struct {
+	// Target is where to head, relative to the bot's center like the
+	// position a client sends
+	Target      utils.Point
+	Fire        bool
+	Sprint      bool
+	Split       bool
+	ChangeShape bool
+}
+
+var (
+	brainsMu sync.RWMutex
+	brains   = make(map[string]func() BotBrain)
+)
+
+func init() {
+	RegisterBrain(SeekerBrain, func() BotBrain { return &seeker{hunt: true} })
+	RegisterBrain(GrazerBrain, func() BotBrain { return &seeker{} })
+}
+
+// RegisterBrain makes a brain available to NewBrain, the botBrain setting and
+// tournaments under name. newBrain is called once for every bot given the
+// brain, so brains can keep state between thoughts. Registering a name again
+// replaces it.
+func RegisterBrain(name string, newBrain func() BotBrain) {
+	brainsMu.Lock()
+	defer brainsMu.Unlock()
+	brains[name] = newBrain
+}
+
+// NewBrain returns a new brain registered under name
+func NewBrain(name string) (BotBrain, error) {
+	brainsMu.RLock()
+	defer brainsMu.RUnlock()
+	newBrain, ok := brains[name]
+	if !ok {
+		return nil, ErrUnknownBrain
+	}
+	return newBrain(), nil
+}
+
+// Brains returns the names of every registered brain in order
+func Brains() []string {
+	brainsMu.RLock()
+	defer brainsMu.RUnlock()
+	names := make([]string, 0, len(brains))
+	for name := range brains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// seeker is the built in brain. It runs from the closest bigger player,
+// sprinting if it is close, and otherwise heads for the nearest food on the
+// map. When hunting it chases and shoots at the closest smaller player before
+// eating.
+type seeker struct {
+	hunt bool
+}
+
+// seekerWander is how far from its center a seeker with nothing better to do
+// picks somewhere to drift towards
+const seekerWander = 500
+
+// Think picks what to do from the view
+func (s *seeker) Think(v *View) Input {
+	p := v.Self
+	var threat, prey *protocol.Player
+	threatDist, preyDist := math.Inf(1), math.Inf(1)
+	for i := range v.Players {
+		u := &v.Players[i]
+		if u.ID == 0 || u.ID == p.ID {
+			continue
+		}
+		d := math.Hypot(u.Point.X-p.Point.X, u.Point.Y-p.Point.Y)
+		switch {
+		case u.MassTotal > p.MassTotal && d < threatDist:
+			threat, threatDist = u, d
+		case p.MassTotal > u.MassTotal && d < preyDist:
+			prey, preyDist = u, d
+		}
+	}
+	switch {
+	case threat != nil:
+		return Input{
+			Target: utils.Point{X: p.Point.X - threat.Point.X, Y: p.Point.Y - threat.Point.Y},
+			Sprint: threatDist < 2*(p.W+threat.W),
+		}
+	case prey != nil && s.hunt:
+		return Input{
+			Target: utils.Point{X: prey.Point.X - p.Point.X, Y: prey.Point.Y - p.Point.Y},
+			Fire:   true,
+		}
+	}
+	if food := v.NearestFood(1); len(food) > 0 {
+		return Input{Target: utils.Point{X: food[0].Point.X - p.Point.X, Y: food[0].Point.Y - p.Point.Y}}
+	}
+	return Input{Target: utils.Point{
+		X: (v.Rand.Float64()*2 - 1) * seekerWander,
+		Y: (v.Rand.Float64()*2 - 1) * seekerWander,
+	}}
+}
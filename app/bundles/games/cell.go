@@ -1,12 +1,40 @@
 // Package games handles everything related to our game
 package games
 
-import "github.com/krishamoud/game/app/common/utils"
+import (
+	"math"
 
-// Cell is the player body
+	"github.com/Tarliton/collision2d"
+	"github.com/krishamoud/game/app/common/utils"
+)
+
+// Cell is the player body. Circle players split into several cells that
+// each carry part of the player's mass and collide on their own.
 type Cell struct {
-	Point  *utils.Point `json:"cell"`
-	Radius float64      `json:"radius"`
-	Mass   float64      `json:"mass"`
-	Speed  float64      `json:"speed"`
+	Point    *utils.Point `json:"cell"`
+	Radius   float64      `json:"radius"`
+	Mass     float64      `json:"mass"`
+	Speed    float64      `json:"speed"` // units per second
+	impulse  utils.Point  // units per second the cell was thrown at when it split off
+	collider collision2d.Circle
+}
+
+// setMass changes the cell's mass and grows or shrinks it to match
+func (c *Cell) setMass(m float64) {
+	c.Mass = m
+	c.Radius = utils.MassToRadius(m)
+}
+
+// drift moves the cell by what is left of its impulse over dt seconds and
+// slows the impulse down
+func (c *Cell) drift(dt float64) {
+	speed := math.Hypot(c.impulse.X, c.impulse.Y)
+	if speed == 0 {
+		return
+	}
+	c.Point.X += c.impulse.X * dt
+	c.Point.Y += c.impulse.Y * dt
+	slowed := math.Max(speed-splitFriction*dt, 0) / speed
+	c.impulse.X *= slowed
+	c.impulse.Y *= slowed
 }
@@ -0,0 +1,61 @@
+// Package games handles everything related to our game
+package games
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Clock tells a game what time it is. Gameplay timers like reloading,
+// sprinting and invincibility read it instead of time.Now, so a simulation
+// with a FakeClock plays out the same way every time.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock games use unless they are given another one
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a Clock that only moves when it is told to. It is safe to
+// use from any goroutine.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock stopped at start
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the time the clock is stopped at
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Rand is where a game gets its random numbers. Only the game loop draws from
+// it, so it doesn't need to be safe for concurrent use. A *rand.Rand is one.
+type Rand interface {
+	Float64() float64
+	Intn(n int) int
+}
+
+// NewRand returns a Rand that always produces the same numbers for the same
+// seed
+func NewRand(seed int64) Rand {
+	return rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,82 @@
+package games
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// simulate plays ticks ticks of a game seeded with seed, with players that
+// wander and shoot, and returns the resulting world as JSON
+func simulate(seed int64, ticks int) []byte {
+	clock := NewFakeClock(time.Unix(0, 0))
+	g := NewGame(conf.Default(), WithSeed(seed), WithClock(clock))
+	codec, _ := g.newCodec("")
+	g.addFood(200)
+	for i := 0; i < 10; i++ {
+		p := NewPlayer(g, player, NewClient(g.cfg, nil, player, codec))
+		p.Name = "bot"
+		g.spawn(p)
+	}
+	for i := 0; i < ticks; i++ {
+		g.Users.Each(func(p *Player) {
+			if i%30 == 0 {
+				p.Target = &utils.Point{X: g.randomIn(-500, 500), Y: g.randomIn(-500, 500)}
+			}
+			if i%45 == 0 {
+				p.Fire(g)
+			}
+		})
+		clock.Advance(g.step)
+		g.MoveLoop()
+		g.balanceMass()
+	}
+	var players []*Player
+	g.Users.Each(func(p *Player) {
+		players = append(players, p)
+	})
+	var food []*Food
+	g.Food.Each(func(f *Food) {
+		food = append(food, f)
+	})
+	world, _ := json.Marshal(struct {
+		Players interface{}
+		Food    interface{}
+	}{playerStates(players), foodStates(food)})
+	return world
+}
+
+func TestDeterminismSpec(t *testing.T) {
+	Convey("Given two games with the same seed and a fake clock", t, func() {
+		Convey("When they play out the same ticks", func() {
+			a, b := simulate(7, 300), simulate(7, 300)
+			Convey("Then they should end up byte for byte identical", func() {
+				So(string(a), ShouldEqual, string(b))
+			})
+		})
+
+		Convey("When one of them has another seed", func() {
+			a, b := simulate(7, 300), simulate(8, 300)
+			Convey("Then they should end up somewhere else", func() {
+				So(string(a), ShouldNotEqual, string(b))
+			})
+		})
+	})
+}
+
+func TestFakeClockSpec(t *testing.T) {
+	Convey("Given a fake clock", t, func() {
+		start := time.Unix(100, 0)
+		clock := NewFakeClock(start)
+
+		Convey("Then it should stand still until it is advanced", func() {
+			So(clock.Now(), ShouldEqual, start)
+			clock.Advance(time.Second)
+			So(clock.Now(), ShouldEqual, start.Add(time.Second))
+		})
+	})
+}
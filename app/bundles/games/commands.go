@@ -0,0 +1,220 @@
+// Package games handles everything related to our game
+package games
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/krishamoud/game/app/common/utils"
+)
+
+// inputQueueSize is how many commands a game buffers between ticks
+const inputQueueSize = 1024
+
+// Command is a client input. Commands are queued by the websocket read
+// goroutines and applied by the game loop at the start of the next tick, so
+// only the game loop ever touches the game state.
+type Command interface {
+	Apply(g *Game)
+}
+
+// GotItCommand spawns the player once the client has loaded
+type GotItCommand struct {
+	Player       *Player
+	Name         string
+	ScreenWidth  float64
+	ScreenHeight float64
+}
+
+// Apply the command to the game
+func (cmd *GotItCommand) Apply(g *Game) {
+	p := cmd.Player
+	p.Name = cmd.Name
+	p.ScreenWidth = cmd.ScreenWidth
+	p.ScreenHeight = cmd.ScreenHeight
+	g.gotIt(p)
+}
+
+// PingCommand answers a client latency check
+type PingCommand struct {
+	Player *Player
+}
+
+// Apply the command to the game
+func (cmd *PingCommand) Apply(g *Game) {
+	cmd.Player.Emit("pongcheck", rawEmptyObj)
+}
+
+// ResizeCommand changes the area the player can see
+type ResizeCommand struct {
+	Player       *Player
+	ScreenWidth  float64
+	ScreenHeight float64
+}
+
+// Apply the command to the game
+func (cmd *ResizeCommand) Apply(g *Game) {
+	cmd.Player.WindowResize(cmd.ScreenWidth, cmd.ScreenHeight)
+}
+
+// RespawnCommand takes the player out of the game until the next GotItCommand
+type RespawnCommand struct {
+	Player *Player
+}
+
+// Apply the command to the game
+func (cmd *RespawnCommand) Apply(g *Game) {
+	p := cmd.Player
+	g.SpliceUser(p.ID)
+	p.Emit("welcome", rawEmptyObj)
+	fmt.Println("[INFO] User " + p.Name + " respawned!")
+}
+
+// DisconnectCommand removes the player from the game for good
+type DisconnectCommand struct {
+	Player *Player
+}
+
+// Apply the command to the game
+func (cmd *DisconnectCommand) Apply(g *Game) {
+	p := cmd.Player
+	g.SpliceUser(p.ID)
+	g.RemovePlayerConnection(p)
+	fmt.Println("[INFO] User " + p.Name + " disconnected!")
+	g.Broadcast(p.ID, "playerDisconnect", rawEmptyObj)
+}
+
+// TargetCommand points the player towards a position relative to its center
+type TargetCommand struct {
+	Player *Player
+	Target utils.Point
+}
+
+// Apply the command to the game
+func (cmd *TargetCommand) Apply(g *Game) {
+	p := cmd.Player
+	p.LastHeartbeat = g.clock.Now()
+	if cmd.Target.X != p.Point.X || cmd.Target.Y != p.Point.Y {
+		p.Target = &utils.Point{
+			X: cmd.Target.X,
+			Y: cmd.Target.Y,
+		}
+	}
+}
+
+// FireCommand shoots the player's weapon
+type FireCommand struct {
+	Player *Player
+}
+
+// Apply the command to the game
+func (cmd *FireCommand) Apply(g *Game) {
+	cmd.Player.Fire(g)
+}
+
+// SprintCommand trades some of the player's mass for a burst of speed
+type SprintCommand struct {
+	Player *Player
+}
+
+// Apply the command to the game
+func (cmd *SprintCommand) Apply(g *Game) {
+	cmd.Player.StartSprinting(g)
+}
+
+// SplitCommand halves the cells of a circle player
+type SplitCommand struct {
+	Player *Player
+}
+
+// Apply the command to the game
+func (cmd *SplitCommand) Apply(g *Game) {
+	cmd.Player.Split(g)
+}
+
+// AckCommand tells the game which snapshot the client last received, so
+// the next delta can be sent against it
+type AckCommand struct {
+	Player *Player
+	Seq    uint32
+}
+
+// Apply the command to the game
+func (cmd *AckCommand) Apply(g *Game) {
+	if t := cmd.Player.Conn.tracker; t != nil {
+		t.Ack(cmd.Seq)
+	}
+}
+
+// screenSize is the payload of gotit and windowResized messages
+type screenSize struct {
+	Name         string  `json:"name"`
+	ScreenWidth  float64 `json:"screenWidth"`
+	ScreenHeight float64 `json:"screenHeight"`
+}
+
+// parseCommand turns a websocket message from p into a command. Unknown
+// message types return a nil command and no error.
+func parseCommand(msg *Message, p *Player) (Command, error) {
+	switch msg.Type {
+	case "gotit":
+		s := screenSize{}
+		if err := json.Unmarshal(msg.Data, &s); err != nil {
+			return nil, err
+		}
+		return &GotItCommand{p, s.Name, s.ScreenWidth, s.ScreenHeight}, nil
+	case "pingcheck":
+		return &PingCommand{p}, nil
+	case "windowResized":
+		s := screenSize{}
+		if err := json.Unmarshal(msg.Data, &s); err != nil {
+			return nil, err
+		}
+		return &ResizeCommand{p, s.ScreenWidth, s.ScreenHeight}, nil
+	case "respawn":
+		return &RespawnCommand{p}, nil
+	case "disconnect":
+		return &DisconnectCommand{p}, nil
+	case "0":
+		t := utils.Point{}
+		if err := json.Unmarshal(msg.Data, &t); err != nil {
+			return nil, err
+		}
+		return &TargetCommand{p, t}, nil
+	case "1":
+		return &SprintCommand{p}, nil
+	case "2":
+		return &FireCommand{p}, nil
+	case "3":
+		return &SplitCommand{p}, nil
+	case "ack":
+		a := struct {
+			Seq uint32 `json:"seq"`
+		}{}
+		if err := json.Unmarshal(msg.Data, &a); err != nil {
+			return nil, err
+		}
+		return &AckCommand{p, a.Seq}, nil
+	}
+	return nil, nil
+}
+
+// Push queues a command for the next tick. It blocks while the queue is full
+// and returns false if the game stops first.
+func (g *Game) Push(cmd Command) bool {
+	select {
+	case g.inputs <- cmd:
+		return true
+	case <-g.ctx.Done():
+		return false
+	}
+}
+
+// drainInputs applies every command queued before the tick started. Commands
+// pushed while draining wait for the next tick.
+func (g *Game) drainInputs() {
+	for n := len(g.inputs); n > 0; n-- {
+		cmd := <-g.inputs
+		cmd.Apply(g)
+	}
+}
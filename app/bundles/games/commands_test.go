@@ -0,0 +1,95 @@
+package games
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// logCommand records that it was applied, and pushes next while applying if
+// it has one
+type logCommand struct {
+	log  *[]int
+	n    int
+	next Command
+}
+
+// Apply the command to the game
+func (cmd *logCommand) Apply(g *Game) {
+	*cmd.log = append(*cmd.log, cmd.n)
+	if cmd.next != nil {
+		g.Push(cmd.next)
+	}
+}
+
+func TestCommandQueueSpec(t *testing.T) {
+	Convey("Given a game with commands queued", t, func() {
+		g := NewGame(conf.Default(), WithSeed(1))
+		var log []int
+		for n := 1; n <= 3; n++ {
+			g.Push(&logCommand{log: &log, n: n})
+		}
+		Convey("Then nothing should be applied before the tick", func() {
+			So(log, ShouldBeEmpty)
+		})
+
+		Convey("When the game ticks", func() {
+			g.MoveLoop()
+			Convey("Then every command should be applied in the order it was pushed", func() {
+				So(log, ShouldResemble, []int{1, 2, 3})
+			})
+		})
+
+		Convey("When a command queues another while it is applied", func() {
+			g.Push(&logCommand{log: &log, n: 4, next: &logCommand{log: &log, n: 5}})
+			g.drainInputs()
+			Convey("Then the new command should wait for the next tick", func() {
+				So(log, ShouldResemble, []int{1, 2, 3, 4})
+				g.drainInputs()
+				So(log, ShouldResemble, []int{1, 2, 3, 4, 5})
+			})
+		})
+
+		Convey("When the queue is full and the game stops", func() {
+			for n := 3; n < inputQueueSize; n++ {
+				g.Push(&logCommand{log: &log, n: n})
+			}
+			g.Stop()
+			Convey("Then pushing should give up instead of blocking", func() {
+				So(g.Push(&logCommand{log: &log}), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestParseCommandSpec(t *testing.T) {
+	Convey("Given a player", t, func() {
+		p := &Player{}
+
+		Convey("When it sends a target", func() {
+			cmd, err := parseCommand(&Message{Type: "0", Data: json.RawMessage(`{"x":10,"y":-5}`)}, p)
+			Convey("Then it should become a target command", func() {
+				So(err, ShouldBeNil)
+				So(cmd, ShouldResemble, &TargetCommand{p, utils.Point{X: 10, Y: -5}})
+			})
+		})
+
+		Convey("When it sends a message that doesn't parse", func() {
+			_, err := parseCommand(&Message{Type: "gotit", Data: json.RawMessage(`"nope"`)}, p)
+			Convey("Then it should be an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When it sends a message nobody knows", func() {
+			cmd, err := parseCommand(&Message{Type: "dance"}, p)
+			Convey("Then it should be ignored", func() {
+				So(cmd, ShouldBeNil)
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
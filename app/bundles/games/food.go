@@ -5,40 +5,44 @@ import (
 	"math"
 
 	"github.com/Tarliton/collision2d"
+	"github.com/krishamoud/game/app/common/protocol"
+	"github.com/krishamoud/game/app/common/spatial"
 	"github.com/krishamoud/game/app/common/utils"
 )
 
 // Food increases player mass
 type Food struct {
-	ID       string       `json:"id"`
+	ID       uint32       `json:"id"`
 	Point    *utils.Point `json:"point"`
 	Hue      int          `json:"hue"`
 	Radius   float64      `json:"radius"`
 	Mass     float64      `json:"mass"`
 	Col      collision2d.Circle
-	PlayerID string
-	Speed    float64
+	PlayerID uint32
+	Speed    float64 // units per second
 	Angle    float64
+	moved    bool
 }
 
-// Update moves the ballistic
-func (f *Food) Update(g *Game) {
+// Update slides the food dt seconds along, slowing it down as it goes
+func (f *Food) Update(g *Game, dt float64) {
 	if f.Speed <= 0 {
 		return
 	}
-	deltaY := f.Speed * math.Sin(f.Angle)
-	deltaX := f.Speed * math.Cos(f.Angle)
+	f.moved = true
+	deltaY := f.Speed * dt * math.Sin(f.Angle)
+	deltaX := f.Speed * dt * math.Cos(f.Angle)
 	f.Point.Y += deltaY
 	f.Point.X += deltaX
 	f.Col.Pos.X += deltaX
 	f.Col.Pos.Y += deltaY
-	if f.Point.X > c.GameWidth-f.Radius {
-		f.Point.X = c.GameWidth - f.Radius
-		f.Col.Pos.X = c.GameWidth - f.Radius
+	if f.Point.X > g.cfg.GameWidth-f.Radius {
+		f.Point.X = g.cfg.GameWidth - f.Radius
+		f.Col.Pos.X = g.cfg.GameWidth - f.Radius
 		f.Speed = 0
 	}
-	if f.Point.Y > c.GameHeight-f.Radius {
-		f.Point.Y = c.GameHeight - f.Radius
+	if f.Point.Y > g.cfg.GameHeight-f.Radius {
+		f.Point.Y = g.cfg.GameHeight - f.Radius
 		f.Col.Pos.Y = f.Radius
 		f.Speed = 0
 	}
@@ -54,5 +58,29 @@ func (f *Food) Update(g *Game) {
 		f.Speed = 0
 	}
 
-	f.Speed -= 0.1
+	f.Speed -= foodFriction * dt
+}
+
+// rect returns the box around the food's collider
+func (f *Food) rect() spatial.Rect {
+	return spatial.Around(f.Col.Pos.X, f.Col.Pos.Y, f.Radius)
+}
+
+// State returns the food as sent to clients
+func (f *Food) State() protocol.Food {
+	return protocol.Food{
+		ID:     f.ID,
+		Point:  protocol.Point{X: f.Point.X, Y: f.Point.Y},
+		Hue:    f.Hue,
+		Radius: f.Radius,
+		Mass:   f.Mass,
+	}
+}
+
+func foodStates(food []*Food) []protocol.Food {
+	states := make([]protocol.Food, len(food))
+	for i, f := range food {
+		states[i] = f.State()
+	}
+	return states
 }
@@ -2,48 +2,83 @@
 package games
 
 import (
-	"container/list"
+	"context"
 	"encoding/json"
+	"fmt"
 	"math"
-	"math/rand"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Tarliton/collision2d"
-	"github.com/krishamoud/game/app/common/db"
-	"github.com/krishamoud/game/app/common/quadtree"
+	"github.com/krishamoud/game/app/common/collection"
+	"github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/profile"
+	"github.com/krishamoud/game/app/common/protocol"
+	"github.com/krishamoud/game/app/common/spatial"
 	"github.com/krishamoud/game/app/common/utils"
+	log "github.com/sirupsen/logrus"
 )
 
-// Game holds the state for the entire game
+// Game holds the state for a single game room
 type Game struct {
-	Users         *list.List
-	Food          *list.List
-	Ballistics    *list.List
-	ClientManager *ClientManager
-	Sockets       map[string]*Client
-	Quadtree      *quadtree.Quadtree
-	mu            *sync.Mutex
+	ID             string
+	Users          *collection.Collection[*Player]
+	Food           *collection.Collection[*Food]
+	Ballistics     *collection.Collection[*Ballistic]
+	Viruses        *collection.Collection[*Virus]
+	bots           *collection.Collection[*bot]
+	ClientManager  *ClientManager
+	Entities       *Registry
+	Sockets        map[uint32]*Client
+	UserIndex      spatial.Index[*Player]
+	FoodIndex      spatial.Index[*Food]
+	BallisticIndex spatial.Index[*Ballistic]
+	VirusIndex     spatial.Index[*Virus]
+	Profile        *profile.Profiler
+	cfg            *conf.Configuration
+	tuning         *sync.RWMutex
+	rand           Rand
+	clock          Clock
+	clients        int
+	playerCount    int32
+	botCount       int32
+	step           time.Duration
+	lastOverrun    time.Time
+	overruns       int
+	inputs         chan Command
+	ctx            context.Context
+	cancel         context.CancelFunc
+	done           chan struct{}
 }
 
-// PushFood adds food to a one of the food arrays
+// PushFood adds food to the game, giving it an id if it doesn't have one
 func (g *Game) PushFood(f *Food) {
-	g.Food.PushFront(f)
+	if f.ID == 0 {
+		f.ID = g.Entities.NextID()
+	}
+	g.Food.Add(f.ID, f)
+	g.FoodIndex.Insert(f.ID, f.rect(), f)
 }
 
 // PopFood removes one food
 func (g *Game) PopFood() {
-	g.Food.Remove(g.Food.Front())
+	if id, _, ok := g.Food.Last(); ok {
+		g.SpliceFood(id)
+	}
 }
 
-// SpliceFood removes food at an index
-func (g *Game) SpliceFood(id string) {
-	for e := g.Food.Front(); e != nil; e = e.Next() {
-		// do something with e.Value
-		if e.Value.(*Food).ID == id {
-			g.Food.Remove(e)
-		}
-	}
+// SpliceFood removes food by id
+func (g *Game) SpliceFood(id uint32) {
+	g.Food.Remove(id)
+	g.FoodIndex.Remove(id)
+}
+
+// GetFood returns the food with the given id or nil
+func (g *Game) GetFood(id uint32) *Food {
+	f, _ := g.Food.Get(id)
+	return f
 }
 
 // AddPlayerConnection adds the players socket to the game
@@ -57,212 +92,306 @@ func (g *Game) RemovePlayerConnection(p *Player) {
 	// delete(g.Sockets, p.ID)
 }
 
-// MoveLoop ticks every player
+// MoveLoop applies queued client input and advances the game by one fixed
+// step
 func (g *Game) MoveLoop() {
-	for e := g.Users.Front(); e != nil; e = e.Next() {
-		p := e.Value.(*Player)
-		g.tickPlayer(p)
-	}
-	for e := g.Ballistics.Front(); e != nil; e = e.Next() {
-		b := e.Value.(*Ballistic)
-		b.Update(g)
-	}
-	for e := g.Food.Front(); e != nil; e = e.Next() {
-		f := e.Value.(*Food)
-		f.Update(g)
-	}
-	g.RefreshQTree()
+	dt := g.step.Seconds()
+	sw := profile.Start()
+	g.drainInputs()
+	g.record(phaseInput, sw.Lap())
+	g.updateBots()
+	g.record(phaseBots, sw.Lap())
+	g.Users.Each(func(p *Player) {
+		g.tickPlayer(p, dt)
+	})
+	g.record(phasePlayers, sw.Lap())
+	g.Ballistics.Each(func(b *Ballistic) {
+		b.Update(g, dt)
+	})
+	g.feedViruses()
+	g.record(phaseBallistics, sw.Lap())
+	g.Food.Each(func(f *Food) {
+		f.Update(g, dt)
+	})
+	g.record(phaseFood, sw.Lap())
+	g.RefreshIndexes()
+	g.record(phaseIndex, sw.Lap())
+	atomic.StoreInt32(&g.playerCount, int32(g.Users.Len()))
+	atomic.StoreInt32(&g.botCount, int32(g.bots.Len()))
+	g.updateMetrics()
+}
+
+// PlayerCount returns how many players were in the game at the end of the
+// last tick. Unlike Users.Len it is safe to call from any goroutine.
+func (g *Game) PlayerCount() int {
+	return int(atomic.LoadInt32(&g.playerCount))
+}
+
+// BotCount returns how many of PlayerCount were bots. It is safe to call from
+// any goroutine.
+func (g *Game) BotCount() int {
+	return int(atomic.LoadInt32(&g.botCount))
 }
 
 // SendUpdates updates all clients to the current game state
 func (g *Game) SendUpdates() {
-	for e := g.Users.Front(); e != nil; e = e.Next() {
-		p := e.Value.(*Player)
-		visibleFood := p.VisibleFood(g)
-		visiblePlayers := p.VisibleCells(g)
-		visibleBallistics := p.VisibleBallistics(g)
-		var m = struct {
-			Players           []*Player    `json:"players"`
-			VisibleFood       []*Food      `json:"visibleFood"`
-			VisibleBallistics []*Ballistic `json:"visibleBallistics"`
-		}{
-			visiblePlayers,
-			visibleFood,
-			visibleBallistics,
+	var encode, send time.Duration
+	g.Users.Each(func(p *Player) {
+		if p.Conn == nil {
+			return
 		}
-		data, _ := json.MarshalIndent(&m, "", "\t")
-		p.Emit("serverTellPlayerMove", data)
-	}
+		sw := profile.Start()
+		u := &protocol.Update{
+			Players:           playerStates(p.VisibleCells(g)),
+			VisibleFood:       foodStates(p.VisibleFood(g)),
+			VisibleBallistics: ballisticStates(p.VisibleBallistics(g)),
+			VisibleViruses:    virusStates(p.VisibleViruses(g)),
+		}
+		m, err := p.Conn.encodeUpdate(u)
+		encode += sw.Lap()
+		if err != nil {
+			log.WithField("error", err).Error("Could not encode update")
+			return
+		}
+		p.Conn.Send(m)
+		send += sw.Lap()
+	})
+	g.record(phaseEncode, encode)
+	g.record(phaseSend, send)
 }
 
-// GameInterval runs GameLoop at 60hz
+// GameInterval steps the simulation tickRate times a second and sends
+// snapshots NetworkUpdateFactor times a second. Steps missed because a tick
+// overran are run on the next one, up to MaxCatchUpTicks at a time.
 func (g *Game) GameInterval() {
-	n := time.Duration(c.NetworkUpdateFactor)
-	updateTicker := time.NewTicker(1000 / n * time.Millisecond)
-	quit := make(chan struct{})
-	func() {
-		for {
-			select {
-			case <-updateTicker.C:
+	defer close(g.done)
+	tickTicker := time.NewTicker(g.step)
+	sendTicker := time.NewTicker(time.Second / time.Duration(g.cfg.NetworkUpdateFactor))
+	s := newStepper(g.step, g.cfg.MaxCatchUpTicks, time.Now())
+	for {
+		select {
+		case now := <-tickTicker.C:
+			g.tuning.RLock()
+			steps, dropped := s.advance(now)
+			for ; steps > 0; steps-- {
 				g.MoveLoop()
-				g.SendUpdates()
+				sw := profile.Start()
 				g.balanceMass()
-			case <-quit:
-				updateTicker.Stop()
-				return
+				g.balanceViruses()
+				g.record(phaseMass, sw.Lap())
+			}
+			if dropped > 0 {
+				fmt.Println("[WARN] Game " + g.ID + " fell behind, dropped " + strconv.Itoa(dropped) + " ticks")
 			}
+			g.tuning.RUnlock()
+			g.endTick(now)
+		case now := <-sendTicker.C:
+			g.tuning.RLock()
+			g.SendUpdates()
+			g.tuning.RUnlock()
+			g.endTick(now)
+		case <-g.ctx.Done():
+			tickTicker.Stop()
+			sendTicker.Stop()
+			forgetMetrics(g.ID)
+			return
 		}
-	}()
+	}
+}
+
+// record adds a sample of how long phase took to the profile and metrics
+func (g *Game) record(phase string, d time.Duration) {
+	g.Profile.Record(phase, d)
+	phaseHistogram.Observe(d.Seconds(), g.ID, phase)
+}
+
+// endTick records how long the game loop was busy since it woke up at start
+// and warns about ticks over budget, at most once a second
+func (g *Game) endTick(start time.Time) {
+	d := time.Since(start)
+	tickHistogram.Observe(d.Seconds(), g.ID)
+	if !g.Profile.Tick(d) {
+		return
+	}
+	g.overruns++
+	if time.Since(g.lastOverrun) < time.Second {
+		return
+	}
+	fmt.Println("[WARN] Game " + g.ID + " ran over its " + g.step.String() + " tick budget " +
+		strconv.Itoa(g.overruns) + " times, last tick took " + d.String())
+	g.lastOverrun = time.Now()
+	g.overruns = 0
+}
+
+// Stop cancels the game's context, which ends the GameInterval loop and
+// closes every client. It is safe to call more than once.
+func (g *Game) Stop() {
+	g.cancel()
 }
 
-func (g *Game) tickPlayer(p *Player) {
-	col := p.GetCollisions(g)
-	pColl := p.GetPlayerCollisions(col)
+// Done is closed once GameInterval has returned
+func (g *Game) Done() <-chan struct{} {
+	return g.done
+}
+
+func (g *Game) tickPlayer(p *Player, dt float64) {
+	pColl := p.GetPlayerCollisions(g)
 	p.checkHeartbeat(g)
 	p.SetCollider()
-	p.movePlayer(pColl)
-	p.reload()
-	p.CheckCollisions(col, g)
+	p.movePlayer(g, pColl, dt)
+	p.reload(g.clock.Now())
+	p.CheckCollisions(g)
 	p.CheckKillPlayer(g)
 }
 
-// RefreshQTree clears and reinserts the quadtree nodes
-func (g *Game) RefreshQTree() {
-	g.Quadtree.Clear()
-	b := quadtree.Bounds{}
-	for e := g.Users.Front(); e != nil; e = e.Next() {
-		u := e.Value.(*Player)
-		var w, h float64
-		if u.Shape == circle {
-			w = utils.MassToRadius(u.MassTotal)
-			h = utils.MassToRadius(u.MassTotal)
-			b.X = u.Point.X
-			b.Y = u.Point.Y
-		} else {
-			w = u.W
-			h = u.H
-			b.X = u.Point.X
-			b.Y = u.Point.Y
+// RefreshIndexes moves the players, ballistics and sliding food in the
+// spatial indexes. Everything else stays where it was inserted.
+func (g *Game) RefreshIndexes() {
+	g.Users.Each(func(u *Player) {
+		g.UserIndex.Move(u.ID, u.rect())
+	})
+	g.Ballistics.Each(func(b *Ballistic) {
+		g.BallisticIndex.Move(b.ID, b.rect())
+	})
+	g.Food.Each(func(f *Food) {
+		if f.moved {
+			g.FoodIndex.Move(f.ID, f.rect())
+			f.moved = false
 		}
-		b.Width = w
-		b.Height = h
-		b.ID = u.ID
-		b.Mass = u.MassTotal
-		b.P = "user"
-		b.Obj = u
-		g.Quadtree.Insert(b)
+	})
+}
+
+// RebuildIndexes clears and refills the spatial indexes
+func (g *Game) RebuildIndexes() {
+	g.UserIndex.Clear()
+	g.FoodIndex.Clear()
+	g.BallisticIndex.Clear()
+	g.VirusIndex.Clear()
+	g.Users.Each(func(u *Player) {
+		g.UserIndex.Insert(u.ID, u.rect(), u)
+	})
+	g.Food.Each(func(f *Food) {
+		g.FoodIndex.Insert(f.ID, f.rect(), f)
+	})
+	g.Ballistics.Each(func(b *Ballistic) {
+		g.BallisticIndex.Insert(b.ID, b.rect(), b)
+	})
+	g.Viruses.Each(func(v *Virus) {
+		g.VirusIndex.Insert(v.ID, v.rect(), v)
+	})
+}
+
+// UseIndex switches the game to spatial indexes of the given kind, one of
+// spatial.Quadtree or spatial.Grid, and reindexes everything. An unknown kind
+// leaves every index as it was. Call it before the game starts or from the
+// game loop.
+func (g *Game) UseIndex(kind string) error {
+	users, err := spatial.New[*Player](kind, g.cfg.GameWidth, g.cfg.GameHeight)
+	if err != nil {
+		return err
 	}
-	for e := g.Food.Front(); e != nil; e = e.Next() {
-		f := e.Value.(*Food)
-		b := quadtree.Bounds{
-			X:      f.Col.Pos.X,
-			Y:      f.Col.Pos.Y,
-			Width:  f.Radius,
-			Height: f.Radius,
-			P:      "food",
-			ID:     f.ID,
-			Obj:    f,
-		}
-		g.Quadtree.Insert(b)
+	food, err := spatial.New[*Food](kind, g.cfg.GameWidth, g.cfg.GameHeight)
+	if err != nil {
+		return err
 	}
-	for e := g.Ballistics.Front(); e != nil; e = e.Next() {
-		b := e.Value.(*Ballistic)
-		bnd := quadtree.Bounds{
-			X:      b.Point.X,
-			Y:      b.Point.Y,
-			Width:  b.Radius,
-			Height: b.Radius,
-			P:      "ballistic",
-			ID:     b.ID,
-			Obj:    b,
-		}
-		g.Quadtree.Insert(bnd)
+	ballistics, err := spatial.New[*Ballistic](kind, g.cfg.GameWidth, g.cfg.GameHeight)
+	if err != nil {
+		return err
 	}
+	viruses, err := spatial.New[*Virus](kind, g.cfg.GameWidth, g.cfg.GameHeight)
+	if err != nil {
+		return err
+	}
+	g.UserIndex, g.FoodIndex, g.BallisticIndex, g.VirusIndex = users, food, ballistics, viruses
+	g.RebuildIndexes()
+	return nil
 }
 
-// PushUser adds a User to the Game.Users list
+// PushUser adds a User to the game, giving it an id if it doesn't have one
 func (g *Game) PushUser(u *Player) {
-	g.Users.PushFront(u)
+	if u.ID == 0 {
+		u.ID = g.Entities.NextID()
+	}
+	g.Users.Add(u.ID, u)
+	g.UserIndex.Insert(u.ID, u.rect(), u)
 }
 
-// SpliceUser removes a User from the Game.Users list
-func (g *Game) SpliceUser(id string) {
-	for e := g.Users.Front(); e != nil; e = e.Next() {
-		p := e.Value.(*Player)
-		if p.ID == id {
-			g.Users.Remove(e)
-			return
-		}
+// SpliceUser removes a User from the game
+func (g *Game) SpliceUser(id uint32) {
+	g.Users.Remove(id)
+	g.UserIndex.Remove(id)
+	g.bots.Remove(id)
+}
+
+// GetPlayer returns the player with the given id or nil
+func (g *Game) GetPlayer(id uint32) *Player {
+	p, _ := g.Users.Get(id)
+	return p
+}
+
+// PushBallistic adds a ballistic to the game, giving it an id if it doesn't
+// have one
+func (g *Game) PushBallistic(b *Ballistic) {
+	if b.ID == 0 {
+		b.ID = g.Entities.NextID()
 	}
+	g.Ballistics.Add(b.ID, b)
+	g.BallisticIndex.Insert(b.ID, b.rect(), b)
 }
 
 // RemoveBallistic removes a ballistic from the game
-func (g *Game) RemoveBallistic(id string) {
-	for e := g.Ballistics.Front(); e != nil; e = e.Next() {
-		b := e.Value.(*Ballistic)
-		if b.ID == id {
-			g.Ballistics.Remove(e)
-			b = nil
-			return
-		}
-	}
+func (g *Game) RemoveBallistic(id uint32) {
+	g.Ballistics.Remove(id)
+	g.BallisticIndex.Remove(id)
 }
 
-// GetBallistic returns a ballistic index
-func (g *Game) GetBallistic(id string) *Ballistic {
-	var b *Ballistic
-	for e := g.Ballistics.Front(); e != nil; e = e.Next() {
-		temp := e.Value.(*Ballistic)
-		if temp.ID == id {
-			return temp
-		}
+// PushVirus adds a virus to the game, giving it an id if it doesn't have one
+func (g *Game) PushVirus(v *Virus) {
+	if v.ID == 0 {
+		v.ID = g.Entities.NextID()
 	}
+	g.Viruses.Add(v.ID, v)
+	g.VirusIndex.Insert(v.ID, v.rect(), v)
+}
+
+// SpliceVirus removes a virus by id
+func (g *Game) SpliceVirus(id uint32) {
+	g.Viruses.Remove(id)
+	g.VirusIndex.Remove(id)
+}
+
+// GetBallistic returns the ballistic with the given id or nil
+func (g *Game) GetBallistic(id uint32) *Ballistic {
+	b, _ := g.Ballistics.Get(id)
 	return b
 }
 
 // Emit sends websocket messages to every player in the game
 func (g *Game) Emit(msg string, body json.RawMessage) {
-	for e := g.Users.Front(); e != nil; e = e.Next() {
-		p := e.Value.(*Player)
-		p.mu.Lock()
-		cn := p.Conn.Conn
-		message := &Message{
-			Type: msg,
-			Data: body,
-		}
-		cn.WriteJSON(message)
-		p.mu.Unlock()
-	}
+	g.Users.Each(func(p *Player) {
+		p.Emit(msg, body)
+	})
 }
 
 // Broadcast sends websocket messages to every player except the playerID that called it
-func (g *Game) Broadcast(pID string, msg string, body json.RawMessage) {
-	for e := g.Users.Front(); e != nil; e = e.Next() {
-		p := e.Value.(*Player)
+func (g *Game) Broadcast(pID uint32, msg string, body json.RawMessage) {
+	g.Users.Each(func(p *Player) {
 		if pID != p.ID {
-			p.mu.Lock()
-			cn := p.Conn.Conn
-			message := &Message{
-				Type: msg,
-				Data: body,
-			}
-			cn.WriteJSON(message)
-			p.mu.Unlock()
+			p.Emit(msg, body)
 		}
-	}
+	})
 }
+
 func (g *Game) addFood(toAdd int) {
-	radius := utils.MassToRadius(c.FoodMass)
+	radius := utils.MassToRadius(g.cfg.FoodMass)
 	for toAdd > 0 {
-		position := utils.RandomPosition(radius)
+		position := g.randomPosition(radius)
 		pos := collision2d.NewVector(position.X, position.Y)
-		id := db.RandomID(12)
 		f := &Food{
-			ID:     id,
 			Point:  position,
 			Radius: radius,
-			Mass:   c.FoodMass,
-			Hue:    rand.Intn(360),
+			Mass:   g.cfg.FoodMass,
+			Hue:    g.rand.Intn(360),
 			Col:    collision2d.NewCircle(pos, radius),
 		}
 		g.PushFood(f)
@@ -270,10 +399,10 @@ func (g *Game) addFood(toAdd int) {
 	}
 }
 func (g *Game) balanceMass() {
-	totalMass := float64(g.Food.Len())*c.FoodMass + g.userMass()
-	massDiff := c.GameMass - totalMass
-	maxFoodDiff := c.MaxFood - float64(g.Food.Len())
-	foodDiff := massDiff/c.FoodMass - maxFoodDiff
+	totalMass := float64(g.Food.Len())*g.cfg.FoodMass + g.userMass()
+	massDiff := g.cfg.GameMass - totalMass
+	maxFoodDiff := g.cfg.MaxFood - float64(g.Food.Len())
+	foodDiff := massDiff/g.cfg.FoodMass - maxFoodDiff
 	foodToAdd := math.Min(float64(foodDiff), float64(maxFoodDiff))
 	foodToRemove := -math.Max(float64(foodDiff), float64(maxFoodDiff))
 	if foodToAdd > 0 {
@@ -0,0 +1,179 @@
+package games
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/spatial"
+	"github.com/krishamoud/game/app/common/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewGameSpec(t *testing.T) {
+	Convey("Given a game made with its own small map", t, func() {
+		cfg := conf.Default()
+		cfg.GameWidth, cfg.GameHeight = 200, 100
+		g := NewGame(cfg, WithID("small"))
+
+		Convey("When food and players are added", func() {
+			g.addFood(50)
+			p := NewPlayer(g, player, &Client{Type: player})
+			g.spawn(p)
+			Convey("Then everything should be on that map", func() {
+				g.Food.Each(func(f *Food) {
+					So(f.Point.X, ShouldBeBetweenOrEqual, 0, 200)
+					So(f.Point.Y, ShouldBeBetweenOrEqual, 0, 100)
+				})
+				So(p.Point.X, ShouldBeBetweenOrEqual, 0, 200)
+				So(p.Point.Y, ShouldBeBetweenOrEqual, 0, 100)
+				So(g.ID, ShouldEqual, "small")
+			})
+		})
+	})
+}
+
+func TestSpawnSpec(t *testing.T) {
+	Convey("Given players that have connected but not joined", t, func() {
+		g := NewGame(conf.Default(), WithSeed(1))
+		a := NewPlayer(g, player, &Client{Type: player})
+		b := NewPlayer(g, player, &Client{Type: player})
+		Convey("Then they should have no shape yet", func() {
+			So(a.Shape, ShouldBeEmpty)
+			So(b.Shape, ShouldBeEmpty)
+		})
+
+		Convey("When they join", func() {
+			g.spawn(a)
+			g.spawn(b)
+			Convey("Then they should alternate between squares and circles", func() {
+				So(a.Shape, ShouldEqual, square)
+				So(b.Shape, ShouldEqual, circle)
+			})
+
+			Convey("Then respawning should keep the shape", func() {
+				g.SpliceUser(a.ID)
+				g.SpliceUser(b.ID)
+				g.spawn(b)
+				So(b.Shape, ShouldEqual, circle)
+			})
+		})
+	})
+}
+
+func TestUseIndexSpec(t *testing.T) {
+	Convey("Given a game with something of everything in it", t, func() {
+		g := NewGame(conf.Default(), WithSeed(1))
+		p := NewPlayer(g, player, &Client{Type: player})
+		g.spawn(p)
+		g.addFood(1)
+		g.addVirus()
+		_, v, _ := g.Viruses.Last()
+		users, food, viruses := g.UserIndex, g.FoodIndex, g.VirusIndex
+
+		Convey("When it switches index", func() {
+			So(g.UseIndex(spatial.Quadtree), ShouldBeNil)
+			Convey("Then every index should be new and still find everything", func() {
+				So(g.UserIndex, ShouldNotEqual, users)
+				So(g.FoodIndex, ShouldNotEqual, food)
+				So(g.VirusIndex, ShouldNotEqual, viruses)
+				So(g.UserIndex.QueryRect(p.rect()), ShouldContain, p)
+				So(g.FoodIndex.Len(), ShouldEqual, 1)
+				So(g.VirusIndex.QueryRect(v.rect()), ShouldContain, v)
+			})
+		})
+
+		Convey("When it is asked for an index that doesn't exist", func() {
+			err := g.UseIndex("octree")
+			Convey("Then it should fail and keep the indexes it had", func() {
+				So(err, ShouldNotBeNil)
+				So(g.UserIndex, ShouldEqual, users)
+				So(g.FoodIndex, ShouldEqual, food)
+				So(g.VirusIndex, ShouldEqual, viruses)
+			})
+		})
+	})
+}
+
+// benchGame returns a game with food food pellets and players players spread
+// over the map
+func benchGame(cfg *conf.Configuration, food, players int) *Game {
+	g := NewGame(cfg, WithID("bench"), WithSeed(1))
+	g.addFood(food)
+	for i := 0; i < players; i++ {
+		p := NewPlayer(g, player, &Client{Type: player})
+		p.ScreenWidth = 1920
+		p.ScreenHeight = 1080
+		g.spawn(p)
+	}
+	return g
+}
+
+// BenchmarkMoveLoop measures one tick with 1000 food and 100 players
+func BenchmarkMoveLoop(b *testing.B) {
+	g := benchGame(conf.Default(), 1000, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.MoveLoop()
+	}
+}
+
+// BenchmarkMoveLoopIndexes runs MoveLoop with each spatial index on a few map
+// sizes, with 1000 food and 100 players heading somewhere
+func BenchmarkMoveLoopIndexes(b *testing.B) {
+	for _, size := range []float64{2500, 5000, 10000} {
+		for _, kind := range []string{spatial.Quadtree, spatial.Grid} {
+			b.Run(fmt.Sprintf("%s/%.0f", kind, size), func(b *testing.B) {
+				cfg := conf.Default()
+				cfg.GameWidth, cfg.GameHeight = size, size
+				g := benchGame(cfg, 1000, 100)
+				if err := g.UseIndex(kind); err != nil {
+					b.Fatal(err)
+				}
+				r := rand.New(rand.NewSource(1))
+				g.Users.Each(func(p *Player) {
+					p.Target = &utils.Point{X: r.Float64()*1000 - 500, Y: r.Float64()*1000 - 500}
+				})
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					g.MoveLoop()
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkVisible measures gathering what every player can see, the bulk of
+// SendUpdates, with 1000 food and 100 players
+func BenchmarkVisible(b *testing.B) {
+	g := benchGame(conf.Default(), 1000, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Users.Each(func(p *Player) {
+			p.VisibleCells(g)
+			p.VisibleFood(g)
+			p.VisibleBallistics(g)
+		})
+	}
+}
+
+// BenchmarkRefreshIndexes measures moving the players in the spatial indexes
+// with 1000 food and 100 players
+func BenchmarkRefreshIndexes(b *testing.B) {
+	g := benchGame(conf.Default(), 1000, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.RefreshIndexes()
+	}
+}
+
+// BenchmarkRebuildIndexes measures rebuilding the spatial indexes with 1000
+// food and 100 players
+func BenchmarkRebuildIndexes(b *testing.B) {
+	g := benchGame(conf.Default(), 1000, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.RebuildIndexes()
+	}
+}
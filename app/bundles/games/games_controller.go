@@ -2,9 +2,14 @@
 package games
 
 import (
+	"crypto/subtle"
+	"encoding/json"
 	"net/http"
+	"strings"
 
+	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/krishamoud/game/app/common/conf"
 	"github.com/krishamoud/game/app/common/controller"
 )
 
@@ -22,8 +27,73 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// Connect starts the user connection to the game
+// Index lists every running game
+func (c *Controller) Index(w http.ResponseWriter, r *http.Request) {
+	c.SendJSON(w, r, Manager.List(), http.StatusOK)
+}
+
+// Profile reports how long the phases of the game's recent ticks took
+func (c *Controller) Profile(w http.ResponseWriter, r *http.Request) {
+	g, err := Manager.Get(mux.Vars(r)["id"])
+	if c.CheckError(err, http.StatusNotFound, w) {
+		return
+	}
+	c.SendJSON(w, r, g.Profile.Stats(), http.StatusOK)
+}
+
+// Config changes live settings of every running game. The body is a JSON
+// object with the settings to change, the Authorization header must be
+// Bearer followed by the adminPass from the config. The response lists the
+// settings that changed and those that need a restart.
+func (c *Controller) Config(w http.ResponseWriter, r *http.Request) {
+	if !admin(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	next := Manager.Tuning()
+	if c.CheckError(json.NewDecoder(r.Body).Decode(next), http.StatusBadRequest, w) {
+		return
+	}
+	changes, ignored, err := Manager.Reload(next, "admin "+r.RemoteAddr)
+	if c.CheckError(err, http.StatusBadRequest, w) {
+		return
+	}
+	c.SendJSON(w, r, struct {
+		Changes []conf.Change `json:"changes"`
+		Ignored []conf.Change `json:"ignored"`
+	}{changes, ignored}, http.StatusOK)
+}
+
+// admin returns true if r carries the admin password. Nobody is admin while
+// the password is empty or still the placeholder.
+func admin(r *http.Request) bool {
+	pass := Manager.Tuning().AdminPass
+	if pass == "" || pass == conf.PlaceholderPass {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(pass)) == 1
+}
+
+// Connect starts the user connection to the game named by the room query
+// parameter, or to the first game with a free slot when no room is given.
+// The codec query parameter picks how state updates are encoded, json
+// unless it is binary, and delta=true asks for delta compressed updates.
 func (c *Controller) Connect(w http.ResponseWriter, r *http.Request) {
+	g, err := Manager.Join(r.FormValue("room"))
+	if err == ErrGameFull || err == ErrShuttingDown {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if c.CheckError(err, http.StatusInternalServerError, w) {
+		return
+	}
+	defer Manager.Leave(g)
+	codec, err := g.newCodec(r.FormValue("codec"))
+	if c.CheckError(err, http.StatusBadRequest, w) {
+		return
+	}
+
 	// upgrade the connection for websockets
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -31,13 +101,13 @@ func (c *Controller) Connect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cn := &Client{
-		Conn: conn,
-		send: make(chan *Message),
-		Type: r.FormValue("type"),
+	cn := NewClient(g.cfg, conn, r.FormValue("type"), codec)
+	if r.FormValue("delta") == "true" {
+		cn.EnableDelta(g.cfg.KeyframeInterval)
 	}
-	// MainGame.ClientManager.addClient <- cn
-	// go cn.WriteJSON()
-	// go cn.read()
-	setupConnection(cn)
+	defer cn.Close()
+	g.ClientManager.Add(cn)
+	defer g.ClientManager.Remove(cn)
+	go cn.WriteJSON()
+	g.setupConnection(cn)
 }
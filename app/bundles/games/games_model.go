@@ -2,17 +2,15 @@
 package games
 
 import (
-	"container/list"
+	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"sync"
 	"time"
 
-	"github.com/gorilla/websocket"
+	"github.com/krishamoud/game/app/common/collection"
 	"github.com/krishamoud/game/app/common/conf"
-	"github.com/krishamoud/game/app/common/db"
-	"github.com/krishamoud/game/app/common/quadtree"
+	"github.com/krishamoud/game/app/common/spatial"
 	"github.com/krishamoud/game/app/common/utils"
 )
 
@@ -22,179 +20,158 @@ const (
 	player = "player"
 )
 
-// MainGame is the single exported game the server runs until I create a GameManager
-var MainGame = &Game{
-	Users:      list.New(),
-	Food:       list.New(),
-	Ballistics: list.New(),
-	mu:         new(sync.Mutex),
-	ClientManager: &ClientManager{
-		clients:      make(map[*Client]bool),
-		broadcast:    make(chan *Message),
-		addClient:    make(chan *Client),
-		removeClient: make(chan *Client),
-	},
-	Sockets: make(map[string]*Client),
-	Quadtree: &quadtree.Quadtree{
-		Bounds: quadtree.Bounds{
-			X:      0,
-			Y:      0,
-			Width:  c.GameWidth,
-			Height: c.GameHeight,
-		},
-		MaxObjects: 200,
-		MaxLevels:  7,
-		Level:      0,
-		Objects:    make([]quadtree.Bounds, 0),
-		Nodes:      make([]quadtree.Quadtree, 0),
-	},
+// Option configures a Game made by NewGame
+type Option func(*Game)
+
+// WithID names the game
+func WithID(id string) Option {
+	return func(g *Game) {
+		g.ID = id
+	}
 }
 
-// Message is a websocket message
-type Message struct {
-	Type string          `json:"type"`
-	Data json.RawMessage `json:"data"`
+// WithRand makes the game draw random numbers from r instead of a source
+// seeded from the time it was made
+func WithRand(r Rand) Option {
+	return func(g *Game) {
+		g.rand = r
+	}
 }
 
-var c = conf.AppConf
-var initMassLog = utils.Log(float64(c.DefaultPlayerMass), float64(c.SlowBase))
-
-func setupConnection(cn *Client) {
-	radius := utils.MassToRadius(c.DefaultPlayerMass)
-	position := utils.RandomPosition(radius)
-	cells := []*Cell{}
-	var shape string
-	var massTotal float64
-	if cn.Type == player {
-		if MainGame.Users.Len()&1 == 1 {
-			shape = circle
-		} else {
-			shape = square
-		}
-		cell := &Cell{
-			Mass: c.DefaultPlayerMass,
-			Point: &utils.Point{
-				X: position.X,
-				Y: position.Y,
-			},
-			Radius: radius,
-		}
-		cells = append(cells, cell)
-		massTotal = c.DefaultPlayerMass
+// WithSeed makes the game draw random numbers from a source seeded with seed
+func WithSeed(seed int64) Option {
+	return WithRand(NewRand(seed))
+}
+
+// withTuning makes the game read lock mu while it reads its config, so
+// whoever shares the config can change it between ticks by write locking mu
+func withTuning(mu *sync.RWMutex) Option {
+	return func(g *Game) {
+		g.tuning = mu
 	}
-	currentPlayer := &Player{
-		ID:            db.RandomID(12),
-		Point:         position,
-		W:             c.DefaultPlayerMass,
-		H:             c.DefaultPlayerMass,
-		Cells:         cells,
-		MassTotal:     massTotal,
-		Hue:           rand.Intn(360),
-		Type:          cn.Type,
-		LastHeartbeat: time.Now(),
-		Target:        &utils.Point{X: 0, Y: 0},
-		Conn:          cn,
-		mu:            new(sync.Mutex),
-		Shape:         shape,
-		msgChan:       make(chan string),
+}
+
+// WithClock makes the game read the time from clock instead of time.Now
+func WithClock(clock Clock) Option {
+	return func(g *Game) {
+		g.clock = clock
 	}
+}
+
+// NewGame returns an empty game with its own client manager and spatial
+// indexes covering the whole map. cfg is shared rather than copied, so live
+// changes made through GameManager.Reload reach the game, at a tick boundary
+// as long as the manager made the game. Call GameInterval
+// to start it ticking.
+func NewGame(cfg *conf.Configuration, opts ...Option) *Game {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &Game{
+		cfg:        cfg,
+		Users:      collection.New[*Player](),
+		Food:       collection.New[*Food](),
+		Ballistics: collection.New[*Ballistic](),
+		Viruses:    collection.New[*Virus](),
+		bots:       collection.New[*bot](),
+		ClientManager: &ClientManager{
+			clients:      make(map[*Client]bool),
+			broadcast:    make(chan *Message),
+			addClient:    make(chan *Client),
+			removeClient: make(chan *Client),
+			done:         make(chan struct{}),
+		},
+		Sockets:        make(map[uint32]*Client),
+		Entities:       NewRegistry(),
+		UserIndex:      newIndex[*Player](cfg),
+		FoodIndex:      newIndex[*Food](cfg),
+		BallisticIndex: newIndex[*Ballistic](cfg),
+		VirusIndex:     newIndex[*Virus](cfg),
+		Profile:        newProfile(cfg),
+		step:           time.Second / time.Duration(tickRate(cfg)),
+		inputs:         make(chan Command, inputQueueSize),
+		ctx:            ctx,
+		cancel:         cancel,
+		done:           make(chan struct{}),
+		clock:          systemClock{},
+		tuning:         new(sync.RWMutex),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.rand == nil {
+		g.rand = NewRand(time.Now().UnixNano())
+	}
+	return g
+}
+
+// newIndex returns the spatial index picked by spatialIndex in the config,
+// or a quadtree if the config names one we don't have
+func newIndex[T any](cfg *conf.Configuration) spatial.Index[T] {
+	idx, err := spatial.New[T](cfg.SpatialIndex, cfg.GameWidth, cfg.GameHeight)
+	if err != nil {
+		fmt.Println("[WARN] " + err.Error() + " " + cfg.SpatialIndex + ", using " + spatial.Quadtree)
+		idx, _ = spatial.New[T](spatial.Quadtree, cfg.GameWidth, cfg.GameHeight)
+	}
+	return idx
+}
+
+// randomPosition returns a random point on the game's map
+func (g *Game) randomPosition(radius float64) *utils.Point {
+	return &utils.Point{
+		X: g.randomIn(0, g.cfg.GameWidth),
+		Y: g.randomIn(0, g.cfg.GameHeight),
+	}
+}
+
+// randomIn returns a random number from from up to to
+func (g *Game) randomIn(from, to float64) float64 {
+	return from + g.rand.Float64()*(to-from)
+}
+
+// Message is a websocket message. Binary messages are written as they are
+// instead of as JSON.
+type Message struct {
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+	binary []byte
+}
+
+func (g *Game) setupConnection(cn *Client) {
+	g.tuning.RLock()
+	currentPlayer := NewPlayer(g, cn.Type, cn)
+	g.tuning.RUnlock()
 
 	for {
 		m := &Message{}
 		err := cn.Conn.ReadJSON(m)
 		if err != nil {
+			g.Push(&DisconnectCommand{currentPlayer})
 			return
 		}
-		MainGame.dispatch(m, currentPlayer)
-	}
-}
-
-func (g *Game) dispatch(msg *Message, p *Player) {
-	switch msg.Type {
-	case "gotit":
-		data := make(map[string]interface{})
-		_ = json.Unmarshal(msg.Data, &data)
-		p.Name = data["name"].(string)
-		p.ScreenHeight = data["screenHeight"].(float64)
-		p.ScreenWidth = data["screenWidth"].(float64)
-		g.gotIt(p)
-		break
-	case "pingcheck":
-		p.Emit("pongcheck", rawEmptyObj)
-		break
-	case "windowResized":
-		data := make(map[string]interface{})
-		err := json.Unmarshal(msg.Data, &data)
+		cmd, err := parseCommand(m, currentPlayer)
 		if err != nil {
-			panic(err)
+			fmt.Println("[WARN] Bad " + m.Type + " message from " + currentPlayer.Name + ": " + err.Error())
+			continue
 		}
-		w := float64(data["screenWidth"].(float64))
-		h := float64(data["screenHeight"].(float64))
-		p.WindowResize(w, h)
-		break
-	case "respawn":
-		g.SpliceUser(p.ID)
-		p.Emit("welcome", rawEmptyObj)
-		fmt.Println("[INFO] User " + p.Name + " respawned!")
-		break
-	case "disconnect":
-		g.SpliceUser(p.ID)
-		g.RemovePlayerConnection(p)
-		fmt.Println("[INFO] User " + p.Name + " disconnected!")
-		g.Broadcast(p.ID, "playerDisconnect", rawEmptyObj)
-		break
-	case "0":
-		p.LastHeartbeat = time.Now()
-		point := make(map[string]interface{})
-		err := json.Unmarshal(msg.Data, &point)
-		if err != nil {
-			panic(err)
+		if cmd == nil {
+			messagesCounter.Inc("in", unknownType)
+			continue
 		}
-		if point["x"].(float64) != p.Point.X || point["y"].(float64) != p.Point.Y {
-			p.Target = &utils.Point{
-				X: point["x"].(float64),
-				Y: point["y"].(float64),
-			}
+		messagesCounter.Inc("in", m.Type)
+		if !g.Push(cmd) {
+			return
 		}
-		break
-	case "2":
-		p.Fire(g)
 	}
 }
 
 func (g *Game) gotIt(p *Player) {
 	if !utils.ValidNickname(p.Name) {
-		p.Conn.Conn.WriteMessage(websocket.TextMessage, []byte("kick"))
+		p.Emit("kick", rawEmptyObj)
+		p.Conn.Close()
 		g.RemovePlayerConnection(p)
 	} else {
 		fmt.Println("[INFO] Player " + p.Name + " connected!")
 		g.AddPlayerConnection(p)
-
-		radius := utils.MassToRadius(c.DefaultPlayerMass)
-		position := utils.RandomPosition(radius)
-
-		p.Point.X = position.X
-		p.Point.Y = position.Y
-		p.Target.X = 0
-		p.Target.Y = 0
-		if p.Type == "player" {
-			cells := []*Cell{
-				&Cell{
-					Mass:   c.DefaultPlayerMass,
-					Point:  &utils.Point{X: position.X, Y: position.Y},
-					Radius: radius,
-				},
-			}
-			p.Cells = cells
-			p.MassTotal = c.DefaultPlayerMass
-		}
-		p.Hue = rand.Intn(360)
-		p.LastHeartbeat = time.Now()
-		p.Scale = 1
-		p.ClipSize = 10
-		p.ShotsLeft = p.ClipSize
-		p.MassCurrent = p.MassTotal
-		g.PushUser(p)
+		g.spawn(p)
 		var n = struct {
 			Name string `json:"name"`
 		}{
@@ -203,11 +180,17 @@ func (g *Game) gotIt(p *Player) {
 		b, _ := json.MarshalIndent(&n, "", "\t")
 		g.Emit("playerJoin", b)
 		var gd = struct {
-			GameWidth  float64 `json:"gameWidth"`
-			GameHeight float64 `json:"gameHeight"`
+			GameWidth        float64 `json:"gameWidth"`
+			GameHeight       float64 `json:"gameHeight"`
+			VirusFill        string  `json:"virusFill"`
+			VirusStroke      string  `json:"virusStroke"`
+			VirusStrokeWidth int     `json:"virusStrokeWidth"`
 		}{
-			c.GameWidth,
-			c.GameHeight,
+			g.cfg.GameWidth,
+			g.cfg.GameHeight,
+			g.cfg.Virus.Fill,
+			g.cfg.Virus.Stroke,
+			g.cfg.Virus.StrokeWidth,
 		}
 		data, _ := json.MarshalIndent(&gd, "", "\t")
 		p.Emit("gameSetup", data)
@@ -215,6 +198,44 @@ func (g *Game) gotIt(p *Player) {
 	}
 }
 
+// spawn puts p somewhere random on the map with the starting mass and a full
+// clip and adds it to the game. Players joining for the first time alternate
+// between squares and circles; respawning keeps the shape.
+func (g *Game) spawn(p *Player) {
+	radius := utils.MassToRadius(g.cfg.DefaultPlayerMass)
+	position := g.randomPosition(radius)
+
+	p.Point.X = position.X
+	p.Point.Y = position.Y
+	p.Target.X = 0
+	p.Target.Y = 0
+	if p.Type == "player" {
+		if p.Shape == "" {
+			if g.Users.Len()&1 == 1 {
+				p.Shape = circle
+			} else {
+				p.Shape = square
+			}
+		}
+		cells := []*Cell{
+			&Cell{
+				Mass:   g.cfg.DefaultPlayerMass,
+				Point:  &utils.Point{X: position.X, Y: position.Y},
+				Radius: radius,
+			},
+		}
+		p.Cells = cells
+		p.MassTotal = g.cfg.DefaultPlayerMass
+	}
+	p.Hue = g.rand.Intn(360)
+	p.LastHeartbeat = g.clock.Now()
+	p.Scale = 1
+	p.ClipSize = 10
+	p.ShotsLeft = p.ClipSize
+	p.MassCurrent = p.MassTotal
+	g.PushUser(p)
+}
+
 func (g *Game) removeFood(toRem int) {
 	for toRem > 0 {
 		g.PopFood()
@@ -224,9 +245,8 @@ func (g *Game) removeFood(toRem int) {
 
 func (g *Game) userMass() float64 {
 	var total float64
-	for e := g.Users.Front(); e != nil; e = e.Next() {
-		u := e.Value.(*Player)
+	g.Users.Each(func(u *Player) {
 		total += u.MassTotal
-	}
+	})
 	return total
 }
@@ -0,0 +1,183 @@
+package games
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/utils"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// sim drives a game without websockets. Inputs are scripted per tick and
+// go through the command queue like the read loops would, then the game
+// steps once with a fake clock, so a sim with the same seed and script
+// always plays out the same way.
+type sim struct {
+	g      *Game
+	clock  *FakeClock
+	tick   int
+	script map[int][]Command
+}
+
+// newSim returns a sim of a small map with a seeded game, no food yet and
+// no bots or viruses
+func newSim(seed int64) *sim {
+	cfg := conf.Default()
+	cfg.GameWidth, cfg.GameHeight = 2000, 2000
+	cfg.GameMass = 1000
+	cfg.MaxFood = 100
+	cfg.MaxVirus = 0
+	cfg.Bots = 0
+	clock := NewFakeClock(time.Unix(0, 0))
+	return &sim{
+		g:      NewGame(cfg, WithID("sim"), WithSeed(seed), WithClock(clock)),
+		clock:  clock,
+		script: make(map[int][]Command),
+	}
+}
+
+// join spawns a player of the given shape centered at x, y
+func (s *sim) join(name, shape string, x, y float64) *Player {
+	codec, _ := s.g.newCodec("")
+	p := NewPlayer(s.g, player, NewClient(s.g.cfg, nil, player, codec))
+	p.Name = name
+	p.ScreenWidth, p.ScreenHeight = 1920, 1080
+	s.g.spawn(p)
+	p.Shape = shape
+	p.Point.X, p.Point.Y = x, y
+	p.Cells[0].Point.X, p.Cells[0].Point.Y = x, y
+	return p
+}
+
+// bot spawns a circle bot played by brain centered at x, y
+func (s *sim) bot(brain BotBrain, x, y float64) *bot {
+	p := s.g.AddBot(brain)
+	p.Shape = circle
+	p.Point.X, p.Point.Y = x, y
+	p.Cells[0].Point.X, p.Cells[0].Point.Y = x, y
+	b, _ := s.g.bots.Get(p.ID)
+	return b
+}
+
+// think makes b think and act straight away
+func (s *sim) think(b *bot) {
+	b.act(s.g, b.brain.Think(b.view(s.g, s.clock.Now())))
+}
+
+// at queues cmds to be applied on the given tick, counting from 0
+func (s *sim) at(tick int, cmds ...Command) {
+	s.script[tick] = append(s.script[tick], cmds...)
+}
+
+// target points p at x, y relative to its center from tick on
+func (s *sim) target(tick int, p *Player, x, y float64) {
+	s.at(tick, &TargetCommand{p, utils.Point{X: x, Y: y}})
+}
+
+// fire shoots p's weapon on tick
+func (s *sim) fire(tick int, p *Player) {
+	s.at(tick, &FireCommand{p})
+}
+
+// sprint makes p sprint on tick
+func (s *sim) sprint(tick int, p *Player) {
+	s.at(tick, &SprintCommand{p})
+}
+
+// run plays ticks ticks
+func (s *sim) run(ticks int) {
+	for end := s.tick + ticks; s.tick < end; s.tick++ {
+		for _, cmd := range s.script[s.tick] {
+			s.g.Push(cmd)
+		}
+		s.clock.Advance(s.g.step)
+		s.g.MoveLoop()
+		s.g.balanceMass()
+		s.g.balanceViruses()
+	}
+}
+
+// alive returns true if p is still in the game
+func (s *sim) alive(p *Player) bool {
+	return s.g.Users.Has(p.ID)
+}
+
+// foodMass returns how much mass is lying around as food
+func (s *sim) foodMass() float64 {
+	var total float64
+	s.g.Food.Each(func(f *Food) {
+		total += f.Mass
+	})
+	return total
+}
+
+// snapshot returns the world as indented JSON, with numbers rounded so tiny
+// floating point differences between platforms don't matter
+func (s *sim) snapshot() []byte {
+	type player struct {
+		Name        string
+		Shape       string
+		X, Y        float64
+		MassTotal   float64
+		MassCurrent float64
+		ShotsLeft   int
+	}
+	type thing struct {
+		X, Y, Mass float64
+		Owner      uint32
+	}
+	world := struct {
+		Tick       int
+		Players    []player
+		Ballistics []thing
+		Food       []thing
+	}{Tick: s.tick}
+	s.g.Users.Each(func(p *Player) {
+		world.Players = append(world.Players, player{
+			p.Name, p.Shape, round(p.Point.X), round(p.Point.Y),
+			round(p.MassTotal), round(p.MassCurrent), p.ShotsLeft,
+		})
+	})
+	s.g.Ballistics.Each(func(b *Ballistic) {
+		world.Ballistics = append(world.Ballistics, thing{round(b.Point.X), round(b.Point.Y), round(b.Mass), b.PlayerID})
+	})
+	s.g.Food.Each(func(f *Food) {
+		world.Food = append(world.Food, thing{round(f.Point.X), round(f.Point.Y), round(f.Mass), f.PlayerID})
+	})
+	sort.Slice(world.Food, func(i, j int) bool {
+		a, b := world.Food[i], world.Food[j]
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Y < b.Y
+	})
+	b, _ := json.MarshalIndent(world, "", "  ")
+	return append(b, '\n')
+}
+
+func round(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
+
+// golden returns the contents of testdata/name.golden, rewriting it with got
+// first when the tests run with -update
+func golden(name string, got []byte) string {
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			panic(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err.Error()
+	}
+	return string(want)
+}
@@ -2,44 +2,96 @@
 package games
 
 import (
-	"fmt"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/protocol"
+	log "github.com/sirupsen/logrus"
 )
 
-// ClientManager Manages all connections to the game
+const (
+	moveMessage        = "serverTellPlayerMove"
+	deltaMessage       = "serverTellPlayerDelta"
+	movePolicyDrop     = "drop"
+	movePolicyCoalesce = "coalesce"
+)
+
+// ClientManager Manages all connections to the game, whether or not they
+// have spawned a player
 type ClientManager struct {
 	clients      map[*Client]bool
 	broadcast    chan *Message
 	addClient    chan *Client
 	removeClient chan *Client
+	done         chan struct{}
 }
 
-// Client is every person connecting to the game
+// Client is every person connecting to the game. Messages to the client are
+// queued with Send and written by the client's own WriteJSON goroutine, so a
+// slow socket never blocks the game loop. The delta tracker belongs to the
+// game loop like the rest of the game state.
 type Client struct {
-	Conn *websocket.Conn
-	send chan *Message
-	Type string
+	Conn         *websocket.Conn
+	send         chan *Message
+	Type         string
+	codec        protocol.Codec
+	tracker      *protocol.Tracker
+	mu           *sync.Mutex
+	move         *Message
+	moveReady    chan struct{}
+	lag          int
+	maxLag       int
+	movePolicy   string
+	closed       chan struct{}
+	closeOnce    sync.Once
+	closeCode    int
+	closeText    string
+	writeTimeout time.Duration
+}
+
+// NewClient wraps a websocket connection with a send queue sized from cfg.
+// State updates are encoded with codec.
+func NewClient(cfg *conf.Configuration, conn *websocket.Conn, t string, codec protocol.Codec) *Client {
+	return &Client{
+		Conn:         conn,
+		send:         make(chan *Message, cfg.SendQueueSize),
+		Type:         t,
+		codec:        codec,
+		mu:           new(sync.Mutex),
+		moveReady:    make(chan struct{}, 1),
+		maxLag:       cfg.MaxSendLag,
+		movePolicy:   cfg.MovePolicy,
+		closed:       make(chan struct{}),
+		writeTimeout: time.Duration(cfg.WriteTimeout) * time.Millisecond,
+	}
 }
 
-// Start the manager
-func (manager *ClientManager) Start(g *Game) {
+// Start the manager. When ctx is done every client is closed with a going
+// away close frame and Start returns.
+func (manager *ClientManager) Start(ctx context.Context) {
+	defer close(manager.done)
 	for {
 		select {
+		case <-ctx.Done():
+			for conn := range manager.clients {
+				conn.CloseWith(websocket.CloseGoingAway, "server shutting down")
+			}
+			return
 		case conn := <-manager.addClient:
 			manager.clients[conn] = true
 			// p := NewPlayer("player", conn)
 		case conn := <-manager.removeClient:
 			if _, ok := manager.clients[conn]; ok {
 				delete(manager.clients, conn)
-				close(conn.send)
+				conn.Close()
 			}
 		case message := <-manager.broadcast:
 			for conn := range manager.clients {
-				select {
-				case conn.send <- message:
-				default:
-					close(conn.send)
+				if !conn.Send(message) {
 					delete(manager.clients, conn)
 				}
 			}
@@ -47,41 +99,214 @@ func (manager *ClientManager) Start(g *Game) {
 	}
 }
 
-// WriteJSON to the client
+// Add registers a client with the manager
+func (manager *ClientManager) Add(conn *Client) {
+	select {
+	case manager.addClient <- conn:
+	case <-manager.done:
+		conn.Close()
+	}
+}
+
+// Remove closes a client and forgets about it
+func (manager *ClientManager) Remove(conn *Client) {
+	select {
+	case manager.removeClient <- conn:
+	case <-manager.done:
+	}
+}
+
+// Broadcast queues a message for every client
+func (manager *ClientManager) Broadcast(message *Message) {
+	select {
+	case manager.broadcast <- message:
+	case <-manager.done:
+	}
+}
+
+// Done is closed once the manager has stopped and closed its clients
+func (manager *ClientManager) Done() <-chan struct{} {
+	return manager.done
+}
+
+// EnableDelta makes the client receive serverTellPlayerDelta messages against
+// the last snapshot it acknowledged instead of full serverTellPlayerMove ones
+func (c *Client) EnableDelta(keyframeInterval int) {
+	c.tracker = protocol.NewTracker(keyframeInterval)
+}
+
+// Send queues a message for the client without blocking. State updates are
+// coalesced or dropped according to MovePolicy when the client can't keep up;
+// any other message that doesn't fit in the queue, or a client that has
+// missed more than MaxSendLag state updates in a row, gets disconnected.
+// Send returns false once the client is closed.
+func (c *Client) Send(m *Message) bool {
+	select {
+	case <-c.closed:
+		return false
+	default:
+	}
+	if m.Type == moveMessage || m.Type == deltaMessage {
+		return c.sendMove(m)
+	}
+	select {
+	case c.send <- m:
+		return true
+	default:
+		log.WithField("type", m.Type).Warn("Send queue full, disconnecting client")
+		c.Close()
+		return false
+	}
+}
+
+func (c *Client) sendMove(m *Message) bool {
+	c.mu.Lock()
+	behind := false
+	if c.movePolicy == movePolicyDrop {
+		// leave half the queue for messages that can't be dropped
+		behind = len(c.send) >= cap(c.send)/2
+		if !behind {
+			select {
+			case c.send <- m:
+			default:
+				behind = true
+			}
+		}
+	} else {
+		behind = c.move != nil
+		c.move = m
+	}
+	if behind {
+		c.lag++
+	} else {
+		c.lag = 0
+	}
+	evict := c.maxLag > 0 && c.lag > c.maxLag
+	c.mu.Unlock()
+
+	if evict {
+		log.WithField("lag", c.lag).Warn("Client fell too far behind, disconnecting")
+		c.Close()
+		return false
+	}
+	select {
+	case c.moveReady <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// encodeUpdate returns the message carrying u for this client
+func (c *Client) encodeUpdate(u *protocol.Update) (*Message, error) {
+	var data []byte
+	var err error
+	m := &Message{Type: moveMessage}
+	if c.tracker != nil {
+		m.Type = deltaMessage
+		data, err = c.codec.EncodeDelta(c.tracker.Next(u))
+	} else {
+		data, err = c.codec.Encode(u)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.codec.Binary() {
+		m.binary = data
+	} else {
+		m.Data = data
+	}
+	return m, nil
+}
+
+// newCodec returns the codec called name for the game's map size
+func (g *Game) newCodec(name string) (protocol.Codec, error) {
+	return protocol.New(name, g.cfg.GameWidth, g.cfg.GameHeight)
+}
+
+// takeMove returns the pending coalesced state update, if any
+func (c *Client) takeMove() *Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := c.move
+	c.move = nil
+	return m
+}
+
+// Close stops the writer, which sends a normal close frame and closes the
+// connection. It is safe to call more than once and from any goroutine.
+func (c *Client) Close() {
+	c.CloseWith(websocket.CloseNormalClosure, "")
+}
+
+// CloseWith is Close with the given close frame code and text. Only the
+// first call to either has any effect.
+func (c *Client) CloseWith(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeText = text
+		close(c.closed)
+	})
+}
+
+// WriteJSON writes queued messages to the client until it is closed or a
+// write fails. Messages still queued when the client is closed, like the
+// serverShutdown notice, are written before the close frame.
 func (c *Client) WriteJSON() {
 	defer func() {
+		c.Close()
 		c.Conn.Close()
 	}()
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+		case message := <-c.send:
+			if c.write(message) != nil {
 				return
 			}
-
-			c.Conn.WriteJSON(message)
+		case <-c.moveReady:
+			if message := c.takeMove(); message != nil && c.write(message) != nil {
+				return
+			}
+		case <-c.closed:
+			c.flush()
+			c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+			c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeText))
+			return
 		}
 	}
 }
 
-func (c *Client) read() {
-	defer func() {
-		MainGame.ClientManager.removeClient <- c
-		c.Conn.Close()
-	}()
-
+// flush writes whatever is left in the send queue without waiting for more
+func (c *Client) flush() {
 	for {
-		m := &Message{}
-		err := c.Conn.ReadJSON(m)
-		if err != nil {
-			MainGame.ClientManager.removeClient <- c
-			c.Conn.Close()
-			break
+		select {
+		case message := <-c.send:
+			if c.write(message) != nil {
+				return
+			}
+		default:
+			return
 		}
-		fmt.Println(m)
-		// msg := <-MainGame.ClientManager.broadcast
-		// fmt.Println(msg)
 	}
 }
+
+// write sends one message, as a binary frame if it was encoded as one and as
+// JSON otherwise. Messages that can't be encoded are logged and skipped.
+func (c *Client) write(message *Message) error {
+	c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	frame, data := websocket.BinaryMessage, message.binary
+	if data == nil {
+		frame = websocket.TextMessage
+		var err error
+		if data, err = json.Marshal(message); err != nil {
+			log.WithField("error", err).Error("Could not encode " + message.Type + " message")
+			return nil
+		}
+	}
+	if err := c.Conn.WriteMessage(frame, data); err != nil {
+		return err
+	}
+	messagesCounter.Inc("out", message.Type)
+	sentBytes.Add(float64(len(data)))
+	return nil
+}
@@ -0,0 +1,145 @@
+package games
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/protocol"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// queuedClient returns a client with no connection, so nothing drains its
+// send queue unless the test does
+func queuedClient(cfg *conf.Configuration) *Client {
+	codec, _ := protocol.New("", cfg.GameWidth, cfg.GameHeight)
+	return NewClient(cfg, nil, player, codec)
+}
+
+func closedClient(c *Client) bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestSendQueueSpec(t *testing.T) {
+	Convey("Given a client that coalesces state updates", t, func() {
+		cfg := conf.Default()
+		cfg.MovePolicy = movePolicyCoalesce
+		cfg.MaxSendLag = 2
+		c := queuedClient(cfg)
+
+		Convey("When updates arrive faster than the writer takes them", func() {
+			first, second := &Message{Type: moveMessage}, &Message{Type: moveMessage}
+			So(c.Send(first), ShouldBeTrue)
+			So(c.Send(second), ShouldBeTrue)
+			Convey("Then only the latest should be waiting", func() {
+				So(c.takeMove(), ShouldEqual, second)
+				So(c.takeMove(), ShouldBeNil)
+				So(len(c.send), ShouldEqual, 0)
+			})
+
+			Convey("Then other messages should still be queued in full", func() {
+				So(c.Send(&Message{Type: "kick"}), ShouldBeTrue)
+				So(len(c.send), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the writer takes every update in time", func() {
+			for i := 0; i < 5; i++ {
+				So(c.Send(&Message{Type: moveMessage}), ShouldBeTrue)
+				c.takeMove()
+			}
+			Convey("Then the client should never fall behind", func() {
+				So(c.lag, ShouldEqual, 0)
+				So(closedClient(c), ShouldBeFalse)
+			})
+		})
+
+		Convey("When it misses more than maxSendLag updates in a row", func() {
+			for i := 0; i < 3; i++ {
+				So(c.Send(&Message{Type: moveMessage}), ShouldBeTrue)
+			}
+			Convey("Then the next update should disconnect it", func() {
+				So(c.Send(&Message{Type: moveMessage}), ShouldBeFalse)
+				So(closedClient(c), ShouldBeTrue)
+				So(c.Send(&Message{Type: "kick"}), ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given a client that drops state updates", t, func() {
+		cfg := conf.Default()
+		cfg.MovePolicy = movePolicyDrop
+		cfg.SendQueueSize = 4
+		cfg.MaxSendLag = 0
+		c := queuedClient(cfg)
+
+		Convey("When updates fill half its queue", func() {
+			for i := 0; i < 5; i++ {
+				So(c.Send(&Message{Type: moveMessage}), ShouldBeTrue)
+			}
+			Convey("Then the rest should be dropped", func() {
+				So(len(c.send), ShouldEqual, 2)
+				So(c.lag, ShouldEqual, 3)
+			})
+
+			Convey("Then the other half should be left for messages that can't be dropped", func() {
+				So(c.Send(&Message{Type: "playerJoin"}), ShouldBeTrue)
+				So(c.Send(&Message{Type: "playerJoin"}), ShouldBeTrue)
+				So(len(c.send), ShouldEqual, 4)
+			})
+
+			Convey("Then a message that doesn't fit should disconnect it", func() {
+				c.Send(&Message{Type: "playerJoin"})
+				c.Send(&Message{Type: "playerJoin"})
+				So(c.Send(&Message{Type: "playerJoin"}), ShouldBeFalse)
+				So(closedClient(c), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestWriterSpec(t *testing.T) {
+	Convey("Given a client closed with messages still queued", t, func() {
+		cfg := conf.Default()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			codec, _ := protocol.New("", cfg.GameWidth, cfg.GameHeight)
+			c := NewClient(cfg, conn, player, codec)
+			c.Send(&Message{Type: shutdownMessage, Data: json.RawMessage(`{"seconds":1}`)})
+			c.Send(&Message{Type: "kick", Data: rawEmptyObj})
+			c.CloseWith(websocket.CloseGoingAway, "server shutting down")
+			c.WriteJSON()
+		}))
+		defer server.Close()
+
+		Convey("When its writer runs", func() {
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			var got []string
+			for {
+				m := &Message{}
+				if err = conn.ReadJSON(m); err != nil {
+					break
+				}
+				got = append(got, m.Type)
+			}
+			Convey("Then every queued message should arrive before the close frame", func() {
+				So(got, ShouldResemble, []string{shutdownMessage, "kick"})
+				So(websocket.IsCloseError(err, websocket.CloseGoingAway), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -0,0 +1,274 @@
+// Package games handles everything related to our game
+package games
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/db"
+)
+
+var (
+	// ErrGameExists is returned when creating a game with an id already in use
+	ErrGameExists = errors.New("game already exists")
+	// ErrGameNotFound is returned when no game has the requested id
+	ErrGameNotFound = errors.New("game not found")
+	// ErrGameFull is returned when a game has no room for another client
+	ErrGameFull = errors.New("game is full")
+	// ErrShuttingDown is returned when joining after Shutdown was called
+	ErrShuttingDown = errors.New("server is shutting down")
+)
+
+// shutdownMessage warns clients the server is about to go away
+const shutdownMessage = "serverShutdown"
+
+// Manager is the GameManager the server routes every connection through. It
+// runs its games with conf.AppConf.
+var Manager = NewGameManager(conf.AppConf)
+
+// GameManager creates, looks up and tears down the games the server runs.
+// Every game has its own GameInterval goroutine, spatial indexes, food and ballistics.
+type GameManager struct {
+	games   map[string]*Game
+	cfg     *conf.Configuration
+	mu      *sync.Mutex
+	closing bool
+	conns   sync.WaitGroup
+	// tuning is read locked by every game while it ticks, so Reload can only
+	// change cfg between ticks
+	tuning *sync.RWMutex
+}
+
+// GameSummary describes a running game for listings
+type GameSummary struct {
+	ID         string `json:"id"`
+	Clients    int    `json:"clients"`
+	Players    int    `json:"players"`
+	Bots       int    `json:"bots"`
+	MaxClients int    `json:"maxClients"`
+}
+
+// NewGameManager returns a manager with no running games. Every game it
+// starts shares cfg.
+func NewGameManager(cfg *conf.Configuration) *GameManager {
+	return &GameManager{
+		games:  make(map[string]*Game),
+		cfg:    cfg,
+		mu:     new(sync.Mutex),
+		tuning: new(sync.RWMutex),
+	}
+}
+
+// Create starts a new game with the given id, or a generated one when id is
+// empty, and returns it
+func (m *GameManager) Create(id string) (*Game, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.create(id)
+}
+
+func (m *GameManager) create(id string) (*Game, error) {
+	if id == "" {
+		id = m.newID()
+	}
+	if _, ok := m.games[id]; ok {
+		return nil, ErrGameExists
+	}
+	g := NewGame(m.cfg, WithID(id), withTuning(m.tuning))
+	m.games[id] = g
+	go g.GameInterval()
+	go g.ClientManager.Start(g.ctx)
+	return g, nil
+}
+
+func (m *GameManager) newID() string {
+	for {
+		id := db.RandomID(6)
+		if _, ok := m.games[id]; !ok {
+			return id
+		}
+	}
+}
+
+// Get returns the game with the given id
+func (m *GameManager) Get(id string) (*Game, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g, ok := m.games[id]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	return g, nil
+}
+
+// List returns a summary of every running game ordered by id
+func (m *GameManager) List() []GameSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]GameSummary, 0, len(m.games))
+	for _, g := range m.sorted() {
+		list = append(list, GameSummary{
+			ID:         g.ID,
+			Clients:    g.clients,
+			Players:    g.PlayerCount(),
+			Bots:       g.BotCount(),
+			MaxClients: m.cfg.MaxRoomPlayers,
+		})
+	}
+	return list
+}
+
+// Remove stops the game with the given id and forgets about it
+func (m *GameManager) Remove(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.remove(id)
+}
+
+func (m *GameManager) remove(id string) error {
+	g, ok := m.games[id]
+	if !ok {
+		return ErrGameNotFound
+	}
+	delete(m.games, id)
+	g.Stop()
+	return nil
+}
+
+// Join reserves a client slot in the game with the given id, creating the game
+// if it doesn't exist yet. An empty id picks the first game with a free slot,
+// or starts a new one when every game is full. Call Leave once the client
+// disconnects.
+func (m *GameManager) Join(id string) (*Game, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closing {
+		return nil, ErrShuttingDown
+	}
+	var g *Game
+	if id == "" {
+		g = m.matchmake()
+	} else if g = m.games[id]; g == nil {
+		var err error
+		if g, err = m.create(id); err != nil {
+			return nil, err
+		}
+	}
+	if g == nil {
+		var err error
+		if g, err = m.create(""); err != nil {
+			return nil, err
+		}
+	}
+	if m.full(g) {
+		return nil, ErrGameFull
+	}
+	m.conns.Add(1)
+	g.clients++
+	clientsGauge.Set(float64(g.clients), g.ID)
+	return g, nil
+}
+
+// Leave releases a client slot taken by Join. Empty games are torn down as
+// long as at least one other game keeps running. Games that were already
+// removed or shut down have forgotten their metrics and are left that way.
+func (m *GameManager) Leave(g *Game) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer m.conns.Done()
+	g.clients--
+	if m.games[g.ID] != g {
+		return
+	}
+	clientsGauge.Set(float64(g.clients), g.ID)
+	if g.clients <= 0 && len(m.games) > 1 {
+		m.remove(g.ID)
+	}
+}
+
+// Shutdown stops every game. Joining fails from the moment it is called.
+// Clients are sent a serverShutdown message every second with the seconds
+// left until countdown runs out, then closed with a going away close frame.
+// Shutdown returns once every game loop has stopped and every client has
+// left, or with ctx's error if that takes longer than ctx allows.
+func (m *GameManager) Shutdown(ctx context.Context, countdown time.Duration) error {
+	m.mu.Lock()
+	m.closing = true
+	games := m.sorted()
+	m.mu.Unlock()
+
+countdown:
+	for left := countdown; left > 0; left -= time.Second {
+		body, _ := json.Marshal(struct {
+			Seconds int `json:"seconds"`
+		}{int(math.Ceil(left.Seconds()))})
+		for _, g := range games {
+			g.ClientManager.Broadcast(&Message{Type: shutdownMessage, Data: body})
+		}
+		wait := time.Second
+		if left < wait {
+			wait = left
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			break countdown
+		}
+	}
+
+	for _, g := range games {
+		g.Stop()
+	}
+	for _, g := range games {
+		select {
+		case <-g.Done():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	left := make(chan struct{})
+	go func() {
+		m.conns.Wait()
+		close(left)
+	}()
+	select {
+	case <-left:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// matchmake returns the first game in id order with a free slot
+func (m *GameManager) matchmake() *Game {
+	for _, g := range m.sorted() {
+		if !m.full(g) {
+			return g
+		}
+	}
+	return nil
+}
+
+func (m *GameManager) sorted() []*Game {
+	games := make([]*Game, 0, len(m.games))
+	for _, g := range m.games {
+		games = append(games, g)
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].ID < games[j].ID
+	})
+	return games
+}
+
+// full returns true when the game can't take another client. The caller must
+// hold the manager lock.
+func (m *GameManager) full(g *Game) bool {
+	return m.cfg.MaxRoomPlayers > 0 && g.clients >= m.cfg.MaxRoomPlayers
+}
@@ -0,0 +1,144 @@
+package games
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/metrics"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGameManagerSpec(t *testing.T) {
+	Convey("Given a manager with room for one client per game", t, func() {
+		cfg := conf.Default()
+		cfg.MaxRoomPlayers = 1
+		m := NewGameManager(cfg)
+		Reset(func() {
+			for _, s := range m.List() {
+				m.Remove(s.ID)
+			}
+		})
+
+		Convey("When games are created", func() {
+			b, _ := m.Create("b")
+			a, _ := m.Create("a")
+			Convey("Then they should be listed by id and found by id", func() {
+				list := m.List()
+				So(list, ShouldHaveLength, 2)
+				So(list[0].ID, ShouldEqual, "a")
+				So(list[1].ID, ShouldEqual, "b")
+				So(list[0].MaxClients, ShouldEqual, 1)
+				g, err := m.Get("b")
+				So(err, ShouldBeNil)
+				So(g == b, ShouldBeTrue)
+				So(g.ID, ShouldEqual, "b")
+				So(g == a, ShouldBeFalse)
+			})
+
+			Convey("Then reusing an id should fail", func() {
+				_, err := m.Create("a")
+				So(err, ShouldEqual, ErrGameExists)
+			})
+
+			Convey("Then removing one should stop it and forget it", func() {
+				So(m.Remove("a"), ShouldBeNil)
+				<-a.Done()
+				_, err := m.Get("a")
+				So(err, ShouldEqual, ErrGameNotFound)
+				So(m.Remove("a"), ShouldEqual, ErrGameNotFound)
+			})
+		})
+
+		Convey("When clients join without naming a room", func() {
+			first, _ := m.Join("")
+			second, _ := m.Join("")
+			Convey("Then each should get a game of their own once the first is full", func() {
+				So(first == second, ShouldBeFalse)
+				So(first.ID, ShouldNotEqual, second.ID)
+				So(m.List(), ShouldHaveLength, 2)
+			})
+
+			Convey("Then a full room should turn away a client asking for it", func() {
+				_, err := m.Join(first.ID)
+				So(err, ShouldEqual, ErrGameFull)
+			})
+
+			Convey("Then leaving should tear down the empty game but keep the last one", func() {
+				m.Leave(first)
+				<-first.Done()
+				So(m.List(), ShouldHaveLength, 1)
+				m.Leave(second)
+				_, err := m.Get(second.ID)
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When a client leaves a game that was already removed", func() {
+			g, _ := m.Join("gone")
+			m.Remove("gone")
+			<-g.Done()
+			m.Leave(g)
+			Convey("Then the game's metrics should stay forgotten", func() {
+				var buf bytes.Buffer
+				metrics.Default.WriteText(&buf)
+				So(buf.String(), ShouldNotContainSubstring, `game="gone"`)
+			})
+		})
+
+		Convey("When a client names a room that doesn't exist", func() {
+			g, err := m.Join("lobby")
+			Convey("Then the room should be made for them", func() {
+				So(err, ShouldBeNil)
+				So(g.ID, ShouldEqual, "lobby")
+				So(m.List()[0].Clients, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestShutdownSpec(t *testing.T) {
+	Convey("Given a manager running two games", t, func() {
+		m := NewGameManager(conf.Default())
+		a, _ := m.Create("a")
+		b, _ := m.Create("b")
+
+		Convey("When it shuts down without a countdown", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			err := m.Shutdown(ctx, 0)
+
+			Convey("Then every game loop should have stopped", func() {
+				So(err, ShouldBeNil)
+				So(closed(a.Done()), ShouldBeTrue)
+				So(closed(b.Done()), ShouldBeTrue)
+			})
+
+			Convey("Then nobody should be able to join", func() {
+				_, err := m.Join("a")
+				So(err, ShouldEqual, ErrShuttingDown)
+			})
+		})
+
+		Convey("When a client never leaves", func() {
+			m.Join("a")
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			Convey("Then shutdown should give up at the deadline", func() {
+				So(m.Shutdown(ctx, 0), ShouldEqual, context.DeadlineExceeded)
+			})
+		})
+	})
+}
+
+func closed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
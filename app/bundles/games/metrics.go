@@ -0,0 +1,61 @@
+// Package games handles everything related to our game
+package games
+
+import (
+	"github.com/krishamoud/game/app/common/metrics"
+)
+
+// Metrics served on /metrics. Series labelled with a game are dropped when
+// the game stops.
+var (
+	clientsGauge    = metrics.Default.NewGauge("game_clients", "Websocket clients connected to a game.", "game")
+	playersGauge    = metrics.Default.NewGauge("game_players", "Players in a game by shape.", "game", "shape")
+	foodGauge       = metrics.Default.NewGauge("game_food", "Food pellets in a game.", "game")
+	maxFoodGauge    = metrics.Default.NewGauge("game_food_max", "Most food pellets a game keeps, maxFood in the config.")
+	massGauge       = metrics.Default.NewGauge("game_mass", "Mass of the food and players in a game.", "game")
+	gameMassGauge   = metrics.Default.NewGauge("game_mass_target", "Mass a game balances its food towards, gameMass in the config.")
+	ballisticsGauge = metrics.Default.NewGauge("game_ballistics", "Ballistics in flight in a game.", "game")
+	virusesGauge    = metrics.Default.NewGauge("game_viruses", "Viruses in a game.", "game")
+	messagesCounter = metrics.Default.NewCounter("game_messages_total", "Websocket messages by direction and type.", "direction", "type")
+	sentBytes       = metrics.Default.NewCounter("game_sent_bytes_total", "Bytes written to websockets.")
+	killsCounter    = metrics.Default.NewCounter("game_kills_total", "Players killed in a game.", "game")
+	tickHistogram   = metrics.Default.NewHistogram("game_tick_seconds", "How long the game loop was busy each time it woke up.", metrics.DefaultBuckets, "game")
+	phaseHistogram  = metrics.Default.NewHistogram("game_tick_phase_seconds", "How long each phase of a tick took.", metrics.DefaultBuckets, "game", "phase")
+)
+
+// unknownType labels messages whose type the server doesn't handle, so
+// clients can't create a series per made up type
+const unknownType = "unknown"
+
+// updateMetrics sets the gauges describing the game state
+func (g *Game) updateMetrics() {
+	var circles, squares int
+	g.Users.Each(func(p *Player) {
+		if p.Shape == circle {
+			circles++
+		} else {
+			squares++
+		}
+	})
+	playersGauge.Set(float64(circles), g.ID, circle)
+	playersGauge.Set(float64(squares), g.ID, square)
+	foodGauge.Set(float64(g.Food.Len()), g.ID)
+	massGauge.Set(float64(g.Food.Len())*g.cfg.FoodMass+g.userMass(), g.ID)
+	ballisticsGauge.Set(float64(g.Ballistics.Len()), g.ID)
+	virusesGauge.Set(float64(g.Viruses.Len()), g.ID)
+	maxFoodGauge.Set(g.cfg.MaxFood)
+	gameMassGauge.Set(g.cfg.GameMass)
+}
+
+// forgetMetrics drops every series labelled with the game id
+func forgetMetrics(id string) {
+	clientsGauge.DeleteMatching("game", id)
+	playersGauge.DeleteMatching("game", id)
+	foodGauge.DeleteMatching("game", id)
+	massGauge.DeleteMatching("game", id)
+	ballisticsGauge.DeleteMatching("game", id)
+	virusesGauge.DeleteMatching("game", id)
+	killsCounter.DeleteMatching("game", id)
+	tickHistogram.DeleteMatching("game", id)
+	phaseHistogram.DeleteMatching("game", id)
+}
@@ -5,13 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
-	"math/rand"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/Tarliton/collision2d"
-	"github.com/krishamoud/game/app/common/db"
-	"github.com/krishamoud/game/app/common/quadtree"
+	"github.com/krishamoud/game/app/common/protocol"
+	"github.com/krishamoud/game/app/common/spatial"
 	"github.com/krishamoud/game/app/common/utils"
 )
 
@@ -22,7 +21,7 @@ const (
 
 // Player controls an individual player state
 type Player struct {
-	ID            string             `json:"id"`
+	ID            uint32             `json:"id"`
 	Name          string             `json:"name"`
 	Point         *utils.Point       `json:"point"`
 	W             float64            `json:"w"`
@@ -48,28 +47,24 @@ type Player struct {
 	ShotsLeft     int                `json:"shotsLeft"`
 	msgChan       chan string
 	lastShot      time.Time
-	mu            *sync.Mutex
 	sprinting     bool
 	sprintStart   time.Time
 	invinc        bool
 	invincStart   time.Time
 }
 
-// NewPlayer returns a new instance of a player
-func NewPlayer(t string, cn *Client) *Player {
-	radius := utils.MassToRadius(c.DefaultPlayerMass)
-	position := utils.RandomPosition(radius)
+// NewPlayer returns a new instance of a player for the game g. It may be
+// called off the game loop, so it doesn't look at the game state and the
+// player is only given a shape, a place on the map and a color once it joins.
+// Bots are players with no client.
+func NewPlayer(g *Game, t string, cn *Client) *Player {
+	radius := utils.MassToRadius(g.cfg.DefaultPlayerMass)
+	position := &utils.Point{}
 	cells := []*Cell{}
-	var shape string
 	var massTotal float64
 	if t == player {
-		if MainGame.Users.Len()&1 == 1 {
-			shape = circle
-		} else {
-			shape = square
-		}
 		cell := &Cell{
-			Mass: c.DefaultPlayerMass,
+			Mass: g.cfg.DefaultPlayerMass,
 			Point: &utils.Point{
 				X: position.X,
 				Y: position.Y,
@@ -77,22 +72,19 @@ func NewPlayer(t string, cn *Client) *Player {
 			Radius: radius,
 		}
 		cells = append(cells, cell)
-		massTotal = c.DefaultPlayerMass
+		massTotal = g.cfg.DefaultPlayerMass
 	}
 	currentPlayer := &Player{
-		ID:            db.RandomID(12),
+		ID:            g.Entities.NextID(),
 		Point:         position,
-		W:             c.DefaultPlayerMass,
-		H:             c.DefaultPlayerMass,
+		W:             g.cfg.DefaultPlayerMass,
+		H:             g.cfg.DefaultPlayerMass,
 		Cells:         cells,
 		MassTotal:     massTotal,
-		Hue:           rand.Intn(360),
-		Type:          cn.Type,
-		LastHeartbeat: time.Now(),
+		Type:          t,
+		LastHeartbeat: g.clock.Now(),
 		Target:        &utils.Point{X: 0, Y: 0},
 		Conn:          cn,
-		mu:            new(sync.Mutex),
-		Shape:         shape,
 		msgChan:       make(chan string),
 	}
 	return currentPlayer
@@ -122,18 +114,13 @@ func (p *Player) VisibleFood(g *Game) []*Food {
 	scaledW := p.ScreenWidth / scale
 	scaledH := p.ScreenHeight / scale
 	count := 0
-	for e := g.Food.Front(); e != nil; e = e.Next() {
-		f := e.Value.(*Food)
-		if f.Point.X > p.Point.X-scaledW/2 &&
-			f.Point.X < p.Point.X+scaledW/2 &&
-			f.Point.Y > p.Point.Y-scaledH/2 &&
-			f.Point.Y < p.Point.Y+scaledH/2 {
-			// if count > 200 {
-			// 	continue
-			// }
-			vf = append(vf, f)
-			count++
-		}
+	view := spatial.Rect{X: p.Point.X - scaledW/2, Y: p.Point.Y - scaledH/2, W: scaledW, H: scaledH}
+	for _, f := range g.FoodIndex.QueryRect(view) {
+		// if count > 200 {
+		// 	continue
+		// }
+		vf = append(vf, f)
+		count++
 	}
 	return vf
 }
@@ -146,112 +133,132 @@ func (p *Player) VisibleBallistics(g *Game) []*Ballistic {
 	scaledW := p.ScreenWidth / scale
 	scaledH := p.ScreenHeight / scale
 	count := 0
-	for e := g.Ballistics.Front(); e != nil; e = e.Next() {
-		b := e.Value.(*Ballistic)
-		if b.Point.X > p.Point.X-scaledW/2 &&
-			b.Point.X < p.Point.X+scaledW/2 &&
-			b.Point.Y > p.Point.Y-scaledH/2 &&
-			b.Point.Y < p.Point.Y+scaledH/2 {
-			if count > 200 {
-				continue
-			}
-			vb = append(vb, b)
-			count++
+	view := spatial.Rect{X: p.Point.X - scaledW/2, Y: p.Point.Y - scaledH/2, W: scaledW, H: scaledH}
+	for _, b := range g.BallisticIndex.QueryRect(view) {
+		if count > 200 {
+			break
 		}
+		vb = append(vb, b)
+		count++
 	}
 	return vb
 }
 
-// VisibleCells returns the player cells visible based on the player window size
+// VisibleViruses returns all viruses the player can see based on their window size
+func (p *Player) VisibleViruses(g *Game) []*Virus {
+	div := math.Min(p.ScreenWidth/4, p.ScreenHeight/4)
+	scale := div / p.W
+	scaledW := p.ScreenWidth / scale
+	scaledH := p.ScreenHeight / scale
+	view := spatial.Rect{X: p.Point.X - scaledW/2, Y: p.Point.Y - scaledH/2, W: scaledW, H: scaledH}
+	return g.VirusIndex.QueryRect(view)
+}
+
+// VisibleCells returns the players visible based on the player window size,
+// each of them once however many of its cells are in view. The player itself
+// is in there without its id and name.
 func (p *Player) VisibleCells(g *Game) []*Player {
 	vc := []*Player{}
 	div := math.Max(p.ScreenWidth/4, p.ScreenHeight/4)
 	scale := div / p.W
 	scaledW := p.ScreenWidth / scale
 	scaledH := p.ScreenHeight / scale
-	for e := g.Users.Front(); e != nil; e = e.Next() {
-		u := e.Value.(*Player)
+	inView := func(x, y, r float64) bool {
+		return x+r > p.Point.X-scaledW/2-40 &&
+			x-r < p.Point.X+scaledW/2+40 &&
+			y+r > p.Point.Y-scaledH/2-40 &&
+			y-r < p.Point.Y+scaledH/2+40
+	}
+	g.Users.Each(func(u *Player) {
+		visible := false
 		if u.Shape == circle {
 			for _, c := range u.Cells {
-				if c.Point.X+c.Radius > p.Point.X-scaledW/2-40 &&
-					c.Point.X-c.Radius < p.Point.X+scaledW/2+40 &&
-					c.Point.Y+c.Radius > p.Point.Y-scaledH/2-40 &&
-					c.Point.Y-c.Radius < p.Point.Y+scaledH/2+40 {
-					if u.ID != p.ID {
-						pl := &Player{
-							ID:          u.ID,
-							Point:       u.Point,
-							Cells:       u.Cells,
-							MassTotal:   u.MassTotal,
-							MassCurrent: u.MassCurrent,
-							Hue:         u.Hue,
-							Name:        u.Name,
-							Shape:       u.Shape,
-							W:           u.W,
-							H:           u.H,
-							EyeAngle:    u.EyeAngle,
-							EyeLength:   u.EyeLength,
-						}
-						vc = append(vc, pl)
-					} else {
-						pl := &Player{
-							Point:       u.Point,
-							Cells:       u.Cells,
-							MassTotal:   u.MassTotal,
-							MassCurrent: u.MassCurrent,
-							Hue:         u.Hue,
-							Shape:       u.Shape,
-							W:           u.W,
-							H:           u.H,
-							EyeAngle:    u.EyeAngle,
-							EyeLength:   u.EyeLength,
-						}
-						vc = append(vc, pl)
-					}
+				if inView(c.Point.X, c.Point.Y, c.Radius) {
+					visible = true
+					break
 				}
 			}
 		} else {
-			if u.Point.X+u.W/2 > p.Point.X-scaledW/2-40 &&
-				u.Point.X-u.W/2 < p.Point.X+scaledW/2+40 &&
-				u.Point.Y+u.W/2 > p.Point.Y-scaledH/2-40 &&
-				u.Point.Y-u.W/2 < p.Point.Y+scaledH/2+40 {
-				if u.ID != p.ID {
-					p := &Player{
-						ID:          u.ID,
-						Point:       u.Point,
-						Cells:       u.Cells,
-						MassTotal:   u.MassTotal,
-						MassCurrent: u.MassCurrent,
-						Hue:         u.Hue,
-						Name:        u.Name,
-						Shape:       u.Shape,
-						W:           u.W,
-						H:           u.H,
-						EyeAngle:    u.EyeAngle,
-						EyeLength:   u.EyeLength,
-					}
-					vc = append(vc, p)
-				} else {
-					p := &Player{
-						Point:       u.Point,
-						Cells:       u.Cells,
-						MassTotal:   u.MassTotal,
-						MassCurrent: u.MassCurrent,
-						Hue:         u.Hue,
-						Shape:       u.Shape,
-						W:           u.W,
-						H:           u.H,
-						EyeAngle:    u.EyeAngle,
-						EyeLength:   u.EyeLength,
-					}
-					vc = append(vc, p)
-				}
-			}
+			visible = inView(u.Point.X, u.Point.Y, u.W/2)
 		}
-	}
+		if !visible {
+			return
+		}
+		pl := &Player{
+			Point:       u.Point,
+			Cells:       u.Cells,
+			MassTotal:   u.MassTotal,
+			MassCurrent: u.MassCurrent,
+			Hue:         u.Hue,
+			Shape:       u.Shape,
+			W:           u.W,
+			H:           u.H,
+			EyeAngle:    u.EyeAngle,
+			EyeLength:   u.EyeLength,
+		}
+		if u.ID != p.ID {
+			pl.ID = u.ID
+			pl.Name = u.Name
+		}
+		vc = append(vc, pl)
+	})
 	return vc
 }
 
+// rect returns the box around the player
+func (p *Player) rect() spatial.Rect {
+	if p.Shape == circle && len(p.Cells) > 1 {
+		r := spatial.Around(p.Cells[0].Point.X, p.Cells[0].Point.Y, p.Cells[0].Radius)
+		for _, cl := range p.Cells[1:] {
+			r = r.Union(spatial.Around(cl.Point.X, cl.Point.Y, cl.Radius))
+		}
+		return r
+	}
+	if p.Shape == circle {
+		return spatial.Around(p.Point.X, p.Point.Y, utils.MassToRadius(p.MassTotal))
+	}
+	return spatial.Rect{
+		X: p.Point.X - p.W/2,
+		Y: p.Point.Y - p.H/2,
+		W: p.W,
+		H: p.H,
+	}
+}
+
+// State returns the player as sent to clients
+func (p *Player) State() protocol.Player {
+	cells := make([]protocol.Cell, len(p.Cells))
+	for i, cl := range p.Cells {
+		cells[i] = protocol.Cell{
+			Point:  protocol.Point{X: cl.Point.X, Y: cl.Point.Y},
+			Radius: cl.Radius,
+			Mass:   cl.Mass,
+		}
+	}
+	return protocol.Player{
+		ID:          p.ID,
+		Name:        p.Name,
+		Point:       protocol.Point{X: p.Point.X, Y: p.Point.Y},
+		W:           p.W,
+		H:           p.H,
+		Cells:       cells,
+		MassTotal:   p.MassTotal,
+		MassCurrent: p.MassCurrent,
+		Hue:         p.Hue,
+		Shape:       p.Shape,
+		EyeAngle:    p.EyeAngle,
+		EyeLength:   p.EyeLength,
+	}
+}
+
+func playerStates(players []*Player) []protocol.Player {
+	states := make([]protocol.Player, len(players))
+	for i, u := range players {
+		states[i] = u.State()
+	}
+	return states
+}
+
 // CheckCircleCollision checks if the player collided with a circle
 func (p *Player) CheckCircleCollision(circle collision2d.Circle) bool {
 	col, _ := collision2d.TestCircleCircle(circle, p.Circle)
@@ -264,16 +271,17 @@ func (p *Player) CheckBoxCollision(circle collision2d.Circle) bool {
 	return col
 }
 
-// Emit sends a websocket message to this player
+// Emit queues a websocket message for this player. Bots have no websocket
+// and drop it.
 func (p *Player) Emit(msg string, body json.RawMessage) {
-	p.mu.Lock()
-	cn := p.Conn.Conn
+	if p.Conn == nil {
+		return
+	}
 	message := &Message{
 		Type: msg,
 		Data: body,
 	}
-	cn.WriteJSON(message)
-	p.mu.Unlock()
+	p.Conn.Send(message)
 }
 
 // SetCollider sets the collider every frame to fit the expanding size
@@ -286,6 +294,12 @@ func (p *Player) SetCollider() {
 			X: p.Point.X,
 			Y: p.Point.Y,
 		}, r)
+		for _, cl := range p.Cells {
+			cl.collider = collision2d.NewCircle(collision2d.Vector{
+				X: cl.Point.X,
+				Y: cl.Point.Y,
+			}, utils.MassToRadius(cl.Mass))
+		}
 	} else {
 		p.Box = collision2d.NewBox(collision2d.Vector{
 			X: p.Point.X - p.W/2,
@@ -294,43 +308,22 @@ func (p *Player) SetCollider() {
 	}
 }
 
-// SetSpeed sets a default speed to 6.25 if a cell is new
+// SetSpeed sets the default speed if a cell is new
 func (p *Player) SetSpeed() {
 	for _, cl := range p.Cells {
 		if cl.Speed == 0 {
-			cl.Speed = 5.00
+			cl.Speed = playerSpeed
 		}
 	}
 }
 
-// GetCollisions gets all collideable things in a game
-func (p *Player) GetCollisions(g *Game) []quadtree.Bounds {
-	b := quadtree.Bounds{}
-	if p.Shape == circle {
-		b = quadtree.Bounds{
-			X:      p.Point.X,
-			Y:      p.Point.Y,
-			Width:  utils.MassToRadius(p.MassTotal),
-			Height: utils.MassToRadius(p.MassTotal),
-		}
-	} else if p.Shape == square {
-		b = quadtree.Bounds{
-			X:      p.Point.X - p.W/2,
-			Y:      p.Point.Y - p.H/2,
-			Width:  p.W,
-			Height: p.H,
-		}
-	}
-	return g.Quadtree.Retrieve(b)
-}
-
-// AddMass adds mass and increases size accordingly
-func (p *Player) AddMass(m float64) {
+// AddMass heals the player by m, and once it is fully healed grows the cell
+// cl that took the mass in
+func (p *Player) AddMass(cl *Cell, m float64) {
 	if p.MassCurrent >= p.MassTotal {
 		p.MassCurrent += m
-		p.Cells[0].Mass += m
+		cl.setMass(cl.Mass + m)
 		p.MassTotal += m
-		p.Cells[0].Radius = utils.MassToRadius(p.Cells[0].Mass)
 		if p.MassCurrent > p.MassTotal {
 			p.MassCurrent = p.MassTotal
 		}
@@ -339,52 +332,57 @@ func (p *Player) AddMass(m float64) {
 	}
 }
 
-// RemoveMass adds mass and increases size accordingly
-func (p *Player) RemoveMass(m float64) {
-	p.Cells[0].Mass -= m
+// RemoveMass shrinks the cell cl, and the player with it, by m
+func (p *Player) RemoveMass(cl *Cell, m float64) {
+	cl.setMass(cl.Mass - m)
 	p.MassTotal -= m
-	p.Cells[0].Radius = utils.MassToRadius(p.Cells[0].Mass)
 }
 
-// FoodCollision Checks the collisions between food and players
-func (p *Player) FoodCollision(f *Food) bool {
-	if p.Shape == circle {
-		return p.CheckCircleCollision(f.Col) && f.PlayerID != p.ID
-	}
-	return p.CheckBoxCollision(f.Col) && f.PlayerID != p.ID
-}
-
-// CheckCollisions checks if the player has collided with something
-func (p *Player) CheckCollisions(collidablePoints []quadtree.Bounds, g *Game) {
-	for _, col := range collidablePoints {
-		switch col.P {
-		case "food":
-			f := &Food{}
-			for e := g.Food.Front(); e != nil; e = e.Next() {
-				// do something with e.Value
-				if col.ID == e.Value.(*Food).ID {
-					f = e.Value.(*Food)
-					continue
-				}
-			}
-			if p.FoodCollision(f) {
-				p.AddMass(f.Mass)
-				g.SpliceFood(col.ID)
-			}
-			break
-		case "ballistic":
-			b := g.GetBallistic(col.ID)
-			if b != nil && b.PlayerID != p.ID {
-				p.BallisticCollision(b, g)
-			}
-			break
-		default:
+// FoodCollision returns the cell that touches the food f, or nil if none do
+// or the player dropped it
+func (p *Player) FoodCollision(f *Food) *Cell {
+	if f.PlayerID == p.ID || len(p.Cells) == 0 {
+		return nil
+	}
+	if p.Shape != circle {
+		if p.CheckBoxCollision(f.Col) {
+			return p.Cells[0]
+		}
+		return nil
+	}
+	for _, cl := range p.Cells {
+		if col, _ := collision2d.TestCircleCircle(f.Col, cl.collider); col {
+			return cl
+		}
+	}
+	return nil
+}
+
+// CheckCollisions eats the food and takes the hits of the ballistics the
+// player overlaps
+func (p *Player) CheckCollisions(g *Game) {
+	r := p.rect()
+	for _, f := range g.FoodIndex.QueryRect(r) {
+		if cl := p.FoodCollision(f); cl != nil {
+			p.AddMass(cl, f.Mass)
+			g.SpliceFood(f.ID)
+		}
+	}
+	for _, b := range g.BallisticIndex.QueryRect(r) {
+		if b.PlayerID != p.ID {
+			p.BallisticCollision(b, g)
+		}
+	}
+	for _, v := range g.VirusIndex.QueryRect(r) {
+		if p.VirusCollision(v, g) {
 			break
 		}
 	}
 }
 
-// BallisticCollision checks if a player collides with a ballistic
+// BallisticCollision checks if a player collides with a ballistic. The cell
+// that was hit loses the mass it bleeds and the player loses health, so the
+// cells always add up to MassTotal.
 func (p *Player) BallisticCollision(b *Ballistic, g *Game) {
 	bloodTotal := 0.1 * p.MassTotal
 	bloodLeak := (b.Mass / p.MassTotal) * bloodTotal
@@ -392,23 +390,29 @@ func (p *Player) BallisticCollision(b *Ballistic, g *Game) {
 	if dmg > p.MassTotal {
 		dmg *= 0.1
 	}
+	hit := func(cl *Cell, overlap collision2d.Vector) {
+		blood := math.Min(bloodLeak, cl.Mass)
+		p.Leak(cl, overlap, blood, b.Degree, g)
+		p.RemoveMass(cl, blood)
+		p.MassCurrent -= dmg
+		g.RemoveBallistic(b.ID)
+	}
 	if p.IsCircle() {
-		if ok, col := collision2d.TestCircleCircle(p.Circle, b.circle); ok {
-			p.Leak(col.OverlapV, bloodLeak, b.Degree, g)
-			p.MassCurrent -= dmg
-			g.RemoveBallistic(b.ID)
+		for _, cl := range p.Cells {
+			if ok, col := collision2d.TestCircleCircle(cl.collider, b.circle); ok {
+				hit(cl, col.OverlapV)
+				return
+			}
 		}
 	} else {
 		if ok, col := collision2d.TestPolygonCircle(p.Box.ToPolygon(), b.circle); ok {
-			p.Leak(col.OverlapV, bloodLeak, b.Degree, g)
-			p.MassCurrent -= dmg
-			g.RemoveBallistic(b.ID)
+			hit(p.Cells[0], col.OverlapV)
 		}
 	}
 }
 
-// Leak spills blood on the map
-func (p *Player) Leak(col collision2d.Vector, bloodMass float64, angle float64, g *Game) {
+// Leak spills blood on the map from the cell cl
+func (p *Player) Leak(cl *Cell, col collision2d.Vector, bloodMass float64, angle float64, g *Game) {
 	var m float64
 	for bloodMass > 0 {
 		if bloodMass < 1 {
@@ -416,20 +420,19 @@ func (p *Player) Leak(col collision2d.Vector, bloodMass float64, angle float64,
 		} else {
 			m = 1
 		}
-		v := collision2d.NewVector(p.Cells[0].Point.X-col.X, p.Cells[0].Point.Y-col.Y)
+		v := collision2d.NewVector(cl.Point.X-col.X, cl.Point.Y-col.Y)
 		r := utils.MassToRadius(m)
 		f := &Food{
-			ID: db.RandomID(12),
 			Point: &utils.Point{
-				X: p.Cells[0].Point.X - col.X,
-				Y: p.Cells[0].Point.Y - col.Y,
+				X: cl.Point.X - col.X,
+				Y: cl.Point.Y - col.Y,
 			},
 			Hue:      p.Hue,
 			Radius:   r,
 			Mass:     m,
 			Col:      collision2d.NewCircle(v, r),
 			PlayerID: p.ID,
-			Speed:    5,
+			Speed:    bloodSpeed,
 			Angle:    angle * math.Pi,
 		}
 		g.PushFood(f)
@@ -440,6 +443,7 @@ func (p *Player) Leak(col collision2d.Vector, bloodMass float64, angle float64,
 // CheckKillPlayer checks to see if we should kill the player
 func (p *Player) CheckKillPlayer(g *Game) {
 	if p.MassCurrent < 0 {
+		killsCounter.Inc(g.ID)
 		p.Explode(g)
 		p.KillMessage()
 		g.RemovePlayerConnection(p)
@@ -447,40 +451,40 @@ func (p *Player) CheckKillPlayer(g *Game) {
 	}
 }
 
-// Explode turns the player to mush and spreads their mass in a radius
+// Explode turns the player to mush and spreads the mass of every cell in a
+// radius around it
 func (p *Player) Explode(g *Game) {
-	bloodTotal := 0.9 * p.MassTotal
 	var m float64
-	for i := float64(0); i < bloodTotal; i++ {
-		rand.Seed(time.Now().Unix())
-		angle := rand.Float64() * math.Pi * 2
-		s := rand.Float64() * 10
-		v := collision2d.NewVector(p.Cells[0].Point.X, p.Cells[0].Point.Y)
-		r := utils.MassToRadius(m)
-		f := &Food{
-			ID: db.RandomID(12),
-			Point: &utils.Point{
-				X: p.Cells[0].Point.X,
-				Y: p.Cells[0].Point.Y,
-			},
-			Hue:      p.Hue,
-			Radius:   r,
-			Mass:     1,
-			Col:      collision2d.NewCircle(v, r),
-			PlayerID: p.ID,
-			Speed:    s,
-			Angle:    angle,
+	for _, cl := range p.Cells {
+		bloodTotal := 0.9 * cl.Mass
+		for i := float64(0); i < bloodTotal; i++ {
+			angle := g.rand.Float64() * math.Pi * 2
+			s := g.rand.Float64() * bloodSpeed * 2
+			v := collision2d.NewVector(cl.Point.X, cl.Point.Y)
+			r := utils.MassToRadius(m)
+			f := &Food{
+				Point: &utils.Point{
+					X: cl.Point.X,
+					Y: cl.Point.Y,
+				},
+				Hue:      p.Hue,
+				Radius:   r,
+				Mass:     1,
+				Col:      collision2d.NewCircle(v, r),
+				PlayerID: p.ID,
+				Speed:    s,
+				Angle:    angle,
+			}
+			g.PushFood(f)
 		}
-		g.PushFood(f)
 	}
 }
 
 // PlayerObstructions checks if two players have collided
-func (p *Player) PlayerObstructions(cols []quadtree.Bounds) ([]*Player, []*Player) {
+func (p *Player) PlayerObstructions(cols []*Player) ([]*Player, []*Player) {
 	bigger := []*Player{}
 	smaller := []*Player{}
-	for _, v := range cols {
-		u := v.Obj.(*Player)
+	for _, u := range cols {
 		if u.MassTotal > p.MassTotal {
 			bigger = append(bigger, u)
 		} else {
@@ -490,43 +494,55 @@ func (p *Player) PlayerObstructions(cols []quadtree.Bounds) ([]*Player, []*Playe
 	return bigger, smaller
 }
 
-// SquarePlayerCollision returns a if a square player collided with another player
+// SquarePlayerCollision returns if a square player collided with any part of
+// another player
 func (p *Player) SquarePlayerCollision(u *Player) (bool, collision2d.Response) {
 	if p.EqualShape(u) {
 		return collision2d.TestPolygonPolygon(p.Box.ToPolygon(), u.Box.ToPolygon())
 	}
-	return collision2d.TestPolygonCircle(p.Box.ToPolygon(), u.Circle)
+	for _, c := range u.Cells {
+		if col, res := collision2d.TestPolygonCircle(p.Box.ToPolygon(), c.collider); col {
+			return col, res
+		}
+	}
+	return false, collision2d.Response{}
 }
 
-// CirclePlayerCollision returns if a circle player collided with another player
-func (p *Player) CirclePlayerCollision(u *Player) (bool, collision2d.Response) {
-	if p.EqualShape(u) {
-		return collision2d.TestCircleCircle(p.Circle, u.Circle)
+// CirclePlayerCollision returns if the cell cl of a circle player collided
+// with any part of another player
+func (p *Player) CirclePlayerCollision(cl *Cell, u *Player) (bool, collision2d.Response) {
+	if !p.EqualShape(u) {
+		return collision2d.TestCirclePolygon(cl.collider, u.Box.ToPolygon())
 	}
-	return collision2d.TestCirclePolygon(p.Circle, u.Box.ToPolygon())
+	for _, c := range u.Cells {
+		if col, res := collision2d.TestCircleCircle(cl.collider, c.collider); col {
+			return col, res
+		}
+	}
+	return false, collision2d.Response{}
 }
 
-// Obstructed returns true if a player can't move in a direction
-func (p *Player) Obstructed(players []*Player) {
+// Obstructed pushes the cell cl back out of the players it ran into
+func (p *Player) Obstructed(cl *Cell, players []*Player) {
 	var col bool
 	res := collision2d.Response{}
 	for _, u := range players {
 		if p.IsCircle() {
-			col, res = p.CirclePlayerCollision(u)
+			col, res = p.CirclePlayerCollision(cl, u)
 		} else {
 			col, res = p.SquarePlayerCollision(u)
 		}
 		if col {
-			p.Cells[0].Point.X -= res.OverlapV.X
-			p.Cells[0].Point.Y -= res.OverlapV.Y
+			cl.Point.X -= res.OverlapV.X
+			cl.Point.Y -= res.OverlapV.Y
 		}
 	}
 }
 
-// ChangeInvinc sets invinc true or false and changes the time as well
-func (p *Player) ChangeInvinc() {
+// ChangeInvinc sets invinc true or false and changes the time to now as well
+func (p *Player) ChangeInvinc(now time.Time) {
 	p.invinc = !p.invinc
-	p.invincStart = time.Now()
+	p.invincStart = now
 }
 
 // IsCircle returns true if shape is circle
@@ -544,10 +560,13 @@ func (p *Player) EqualShape(u *Player) bool {
 	return p.Shape == u.Shape
 }
 
-// ChangeShape changes the player from one shape to the other
-func (p *Player) ChangeShape() {
-	p.ChangeInvinc()
+// ChangeShape changes the player from one shape to the other, making it
+// invincible for a moment from now. Squares can't split, so a split circle
+// pulls its cells back together first.
+func (p *Player) ChangeShape(now time.Time) {
+	p.ChangeInvinc(now)
 	if p.Shape == circle {
+		p.mergeAll()
 		p.Shape = square
 	} else {
 		p.Shape = circle
@@ -559,9 +578,9 @@ func (p *Player) CheckAmmo() bool {
 	return p.ClipSize > p.ShotsLeft
 }
 
-// reload reloads the players weapon
-func (p *Player) reload() {
-	now := time.Now()
+// reload reloads the players weapon if it is now more than a second since the
+// last shot or reload
+func (p *Player) reload(now time.Time) {
 	t := p.lastShot.Add(time.Second)
 	if now.After(t) && p.CheckAmmo() {
 		p.ShotsLeft++
@@ -569,10 +588,10 @@ func (p *Player) reload() {
 	}
 }
 
-// Invincible returns a bool depending on if a player is Invincible
-func (p *Player) Invincible() bool {
+// Invincible returns a bool depending on if a player is Invincible now
+func (p *Player) Invincible(now time.Time) bool {
 	t := time.Millisecond * 500
-	return p.invinc && time.Since(p.invincStart) < t
+	return p.invinc && now.Sub(p.invincStart) < t
 }
 
 // Bigger returns a bool if a player is big enough to eat another player
@@ -594,21 +613,19 @@ func (p *Player) KillMessage() {
 
 // StartSprinting decreases the players mass and sets the sprint value
 func (p *Player) StartSprinting(g *Game) {
-	if p.MassTotal <= c.DefaultPlayerMass*1.2 || p.sprinting {
+	if p.MassTotal <= g.cfg.DefaultPlayerMass*1.2 || p.sprinting {
 		return
 	}
 
 	// Start sprinting
-	p.sprintStart = time.Now()
+	p.sprintStart = g.clock.Now()
 	p.sprinting = true
 
-	// Lose 20% of current mass
+	// Lose 20% of current mass, from every cell alike
 	massLost := p.MassTotal * 0.2
 	p.MassTotal -= massLost
-	r := utils.MassToRadius(p.MassTotal)
-	if len(p.Cells) > 0 {
-		p.Cells[0].Radius = r
-		p.Cells[0].Mass -= massLost
+	for _, cl := range p.Cells {
+		cl.setMass(cl.Mass - cl.Mass*0.2)
 	}
 
 	// push the food into the game
@@ -624,8 +641,8 @@ func (p *Player) StartSprinting(g *Game) {
 		radius := utils.MassToRadius(foodMass)
 
 		// Create a random point to help distribute the food a little
-		rx := utils.RandomInRange(-radius*2, radius*2)
-		ry := utils.RandomInRange(-radius*2, radius*2)
+		rx := g.randomIn(-radius*2, radius*2)
+		ry := g.randomIn(-radius*2, radius*2)
 
 		// Create the point where food will appear
 		position := &utils.Point{
@@ -637,7 +654,6 @@ func (p *Player) StartSprinting(g *Game) {
 		colPoint := collision2d.NewVector(position.X, position.Y)
 
 		g.PushFood(&Food{
-			ID:       db.RandomID(12),
 			Point:    position,
 			Radius:   radius,
 			Mass:     foodMass,
@@ -675,62 +691,61 @@ func (p *Player) Fire(g *Game) {
 		X: tp.X + math.Cos(d3)*(p.W/2),
 		Y: tp.Y + math.Sin(d3)*(p.H/2),
 	}
-	var baseSpeed float64 = 15
+	var baseSpeed float64 = ballisticSpeed
 	var b1, b2, b3 *Ballistic
 	w := p.W
 	dist := 8 * w
 	pID := p.ID
 	mass := p.MassTotal * 0.1
 	if p.ShotsLeft > 0 {
-		p.lastShot = time.Now()
+		p.lastShot = g.clock.Now()
 		if p.Shape == circle {
 			mass = mass / 3
 			b1 = NewBallistic(pID, baseSpeed, mass, p1, deg, dist)
 			b2 = NewBallistic(pID, baseSpeed, mass, p2, d2, dist)
 			b3 = NewBallistic(pID, baseSpeed, mass, p3, d3, dist)
-			g.Ballistics.PushFront(b1)
-			g.Ballistics.PushFront(b2)
-			g.Ballistics.PushFront(b3)
+			g.PushBallistic(b1)
+			g.PushBallistic(b2)
+			g.PushBallistic(b3)
 		} else {
 			b1 = NewBallistic(pID, baseSpeed, mass, p1, deg, dist)
-			g.Ballistics.PushFront(b1)
+			g.PushBallistic(b1)
 		}
 		p.ShotsLeft--
 	}
 }
 
-// ShouldSprint returns true if the player should sprintStart
-func (p *Player) ShouldSprint() bool {
+// ShouldSprint returns true if the player should still be sprinting now
+func (p *Player) ShouldSprint(now time.Time) bool {
 	t := p.sprintStart
 	s := time.Millisecond * 1500
-	if p.sprinting && time.Since(t) < s {
+	if p.sprinting && now.Sub(t) < s {
 		return true
 	}
 	p.sprinting = false
 	return false
 }
 
-// GetPlayerCollisions returns only player colliders
-func (p *Player) GetPlayerCollisions(col []quadtree.Bounds) []quadtree.Bounds {
-	b := []quadtree.Bounds{}
-	for _, v := range col {
-		if v.P == "user" && v.ID != p.ID {
-			b = append(b, v)
+// GetPlayerCollisions returns the other players overlapping this one
+func (p *Player) GetPlayerCollisions(g *Game) []*Player {
+	b := []*Player{}
+	for _, u := range g.UserIndex.QueryRect(p.rect()) {
+		if u.ID != p.ID {
+			b = append(b, u)
 		}
 	}
 	return b
 }
 
-func (p *Player) movePlayer(cols []quadtree.Bounds) {
-	var x, y float64
-	for i, cl := range p.Cells {
+func (p *Player) movePlayer(g *Game, cols []*Player, dt float64) {
+	for _, cl := range p.Cells {
 		target := &utils.Point{
 			X: p.Point.X - cl.Point.X + p.Target.X,
 			Y: p.Point.Y - cl.Point.Y + p.Target.Y,
 		}
 		bp, _ := p.PlayerObstructions(cols)
 		var dist float64
-		inv := p.Invincible()
+		inv := p.Invincible(g.clock.Now())
 		if !inv {
 			p.invinc = false
 			dist = utils.GetHypotenuse(target.X, target.Y)
@@ -738,28 +753,35 @@ func (p *Player) movePlayer(cols []quadtree.Bounds) {
 
 		deg := math.Atan2(float64(target.Y), float64(target.X))
 		p.EyeAngle = deg
-		cl.Speed = 5.00
-		if p.ShouldSprint() || inv {
-			cl.Speed = 7.25
+		cl.Speed = playerSpeed
+		if p.ShouldSprint(g.clock.Now()) || inv {
+			cl.Speed = sprintSpeed
 		}
-		deltaX := cl.Speed * math.Cos(deg)
-		deltaY := cl.Speed * math.Sin(deg)
+		deltaX := cl.Speed * dt * math.Cos(deg)
+		deltaY := cl.Speed * dt * math.Sin(deg)
 		if dist < cl.Radius/3 {
 			deltaY *= dist / float64((cl.Radius / 3))
 			deltaX *= dist / float64((cl.Radius / 3))
 		}
-		s := deltaX / (cl.Speed * math.Cos(deg))
+		s := deltaX / (cl.Speed * dt * math.Cos(deg))
 		p.EyeLength = s
 		cl.Point.Y += deltaY
 		cl.Point.X += deltaX
-		p.Obstructed(bp)
+		cl.drift(dt)
+		p.Obstructed(cl, bp)
+	}
+	if len(p.Cells) > 1 {
+		p.settleCells(g.clock.Now(), time.Duration(g.cfg.MergeTimer)*time.Second)
+	}
+	var x, y float64
+	for i := range p.Cells {
 		borderCalc := p.Cells[i].Radius / 3
 		if p.Shape == circle {
-			if p.Cells[i].Point.X > c.GameWidth-borderCalc {
-				p.Cells[i].Point.X = c.GameWidth - borderCalc
+			if p.Cells[i].Point.X > g.cfg.GameWidth-borderCalc {
+				p.Cells[i].Point.X = g.cfg.GameWidth - borderCalc
 			}
-			if p.Cells[i].Point.Y > c.GameHeight-borderCalc {
-				p.Cells[i].Point.Y = c.GameHeight - borderCalc
+			if p.Cells[i].Point.Y > g.cfg.GameHeight-borderCalc {
+				p.Cells[i].Point.Y = g.cfg.GameHeight - borderCalc
 			}
 
 			if p.Cells[i].Point.X < borderCalc {
@@ -769,11 +791,11 @@ func (p *Player) movePlayer(cols []quadtree.Bounds) {
 				p.Cells[i].Point.Y = borderCalc
 			}
 		} else if p.Shape == square {
-			if p.Cells[i].Point.X > c.GameWidth-p.W/2 {
-				p.Cells[i].Point.X = c.GameWidth - p.W/2
+			if p.Cells[i].Point.X > g.cfg.GameWidth-p.W/2 {
+				p.Cells[i].Point.X = g.cfg.GameWidth - p.W/2
 			}
-			if p.Cells[i].Point.Y > c.GameHeight-p.H/2 {
-				p.Cells[i].Point.Y = c.GameHeight - p.H/2
+			if p.Cells[i].Point.Y > g.cfg.GameHeight-p.H/2 {
+				p.Cells[i].Point.Y = g.cfg.GameHeight - p.H/2
 			}
 
 			if p.Cells[i].Point.X <= 0 {
@@ -792,11 +814,11 @@ func (p *Player) movePlayer(cols []quadtree.Bounds) {
 }
 
 func (p *Player) checkHeartbeat(g *Game) {
-	// hbDuration := time.Duration(c.MaxHeartBeatInterval) * time.Millisecond
+	// hbDuration := time.Duration(g.cfg.MaxHeartBeatInterval) * time.Millisecond
 	if false {
-		// if p.LastHeartbeat.Before(time.Now().Add(-hbDuration)) {
+		// if p.LastHeartbeat.Before(g.clock.Now().Add(-hbDuration)) {
 		fmt.Println("Kicking for inactivity")
-		str := "Last heartbeat recieved over " + string(c.MaxHeartBeatInterval) + " ms ago"
+		str := "Last heartbeat recieved over " + strconv.Itoa(g.cfg.MaxHeartBeatInterval) + " ms ago"
 		var m = struct {
 			Msg string `jsong:"msg"`
 		}{
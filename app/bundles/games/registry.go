@@ -0,0 +1,21 @@
+// Package games handles everything related to our game
+package games
+
+import "sync/atomic"
+
+// Registry hands out the ids of every player, food pellet and ballistic in a
+// game. Ids are never reused and the first one is 1, so 0 can mean no entity.
+// The entities themselves live in the game's id indexed collections.
+type Registry struct {
+	next uint32
+}
+
+// NewRegistry returns a registry whose next id is 1
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NextID returns a new id. It is safe to call from any goroutine.
+func (r *Registry) NextID() uint32 {
+	return atomic.AddUint32(&r.next, 1)
+}
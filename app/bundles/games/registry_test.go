@@ -0,0 +1,73 @@
+package games
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistrySpec(t *testing.T) {
+	Convey("Given a new registry", t, func() {
+		r := NewRegistry()
+
+		Convey("Then ids should start at 1 and go up", func() {
+			So(r.NextID(), ShouldEqual, 1)
+			So(r.NextID(), ShouldEqual, 2)
+			So(r.NextID(), ShouldEqual, 3)
+		})
+
+		Convey("When many goroutines ask for ids at once", func() {
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			seen := make(map[uint32]bool)
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 1000; j++ {
+						id := r.NextID()
+						mu.Lock()
+						seen[id] = true
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			Convey("Then none should get the same id", func() {
+				So(seen, ShouldHaveLength, 8000)
+				So(seen[0], ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given a game", t, func() {
+		g := NewGame(conf.Default(), WithSeed(1))
+
+		Convey("When players, food and ballistics are added", func() {
+			p := NewPlayer(g, player, &Client{Type: player})
+			g.spawn(p)
+			g.addFood(1)
+			_, f, _ := g.Food.Last()
+			b := NewBallistic(p.ID, ballisticSpeed, 1, &utils.Point{X: 1, Y: 1}, 0, 10)
+			g.PushBallistic(b)
+			Convey("Then they should all have different ids and be found by them", func() {
+				So(p.ID, ShouldNotEqual, f.ID)
+				So(b.ID, ShouldNotEqual, f.ID)
+				So(b.ID, ShouldNotEqual, p.ID)
+				So(g.GetPlayer(p.ID), ShouldEqual, p)
+				So(g.GetFood(f.ID), ShouldEqual, f)
+				So(g.GetBallistic(b.ID), ShouldEqual, b)
+			})
+
+			Convey("Then removed entities should not be found", func() {
+				g.SpliceFood(f.ID)
+				g.RemoveBallistic(b.ID)
+				So(g.GetFood(f.ID), ShouldBeNil)
+				So(g.GetBallistic(b.ID), ShouldBeNil)
+			})
+		})
+	})
+}
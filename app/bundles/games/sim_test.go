@@ -0,0 +1,147 @@
+package games
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMovementSpec(t *testing.T) {
+	Convey("Given a player in the middle of the map", t, func() {
+		s := newSim(1)
+		p := s.join("walker", circle, 1000, 1000)
+
+		Convey("When it heads right for a second", func() {
+			s.target(0, p, 500, 0)
+			s.run(60)
+			Convey("Then it should have covered playerSpeed units", func() {
+				So(p.Point.X, ShouldAlmostEqual, 1000+playerSpeed, 1)
+				So(p.Point.Y, ShouldAlmostEqual, 1000, 1)
+			})
+		})
+
+		Convey("When it is big enough to sprint and sprints right for a second", func() {
+			p.MassTotal, p.MassCurrent, p.Cells[0].Mass = 100, 100, 100
+			s.target(0, p, 500, 0)
+			s.sprint(0, p)
+			s.run(1)
+			Convey("Then it should have dropped a fifth of its mass as food", func() {
+				So(p.MassTotal, ShouldEqual, 80)
+				var dropped float64
+				s.g.Food.Each(func(f *Food) {
+					if f.PlayerID == p.ID {
+						dropped += f.Mass
+					}
+				})
+				So(dropped, ShouldEqual, 20)
+			})
+			Convey("Then it should have covered sprintSpeed units", func() {
+				s.run(59)
+				So(p.Point.X, ShouldAlmostEqual, 1000+sprintSpeed, 1)
+			})
+		})
+
+		Convey("When it heads for the wall", func() {
+			s.target(0, p, -500, 0)
+			s.run(300)
+			Convey("Then it should stop at the edge of the map", func() {
+				So(p.Point.X, ShouldAlmostEqual, p.Cells[0].Radius/3, 0.001)
+			})
+		})
+	})
+}
+
+func TestShootingSpec(t *testing.T) {
+	Convey("Given a square facing a circle 300 units to its right", t, func() {
+		s := newSim(1)
+		shooter := s.join("shooter", square, 700, 1000)
+		victim := s.join("victim", circle, 1000, 1000)
+		s.target(0, shooter, 300, 0)
+
+		Convey("When the square fires once", func() {
+			s.fire(1, shooter)
+			s.run(30)
+			Convey("Then the circle should be hurt and bleeding", func() {
+				So(shooter.ShotsLeft, ShouldEqual, shooter.ClipSize-1)
+				So(victim.MassCurrent, ShouldBeLessThan, victim.MassTotal)
+				So(s.g.Ballistics.Len(), ShouldEqual, 0)
+			})
+
+			Convey("Then the square should reload a second later", func() {
+				s.run(60)
+				So(shooter.ShotsLeft, ShouldEqual, shooter.ClipSize)
+			})
+		})
+
+		Convey("When the circle is nearly dead and gets hit", func() {
+			victim.MassCurrent = 1
+			s.fire(1, shooter)
+			s.run(30)
+			Convey("Then it should be killed and explode into food", func() {
+				So(s.alive(victim), ShouldBeFalse)
+				So(s.alive(shooter), ShouldBeTrue)
+				So(s.foodMass(), ShouldBeGreaterThanOrEqualTo, victim.MassTotal*0.9)
+			})
+		})
+	})
+}
+
+func TestBalanceMassSpec(t *testing.T) {
+	Convey("Given an empty game", t, func() {
+		s := newSim(1)
+
+		Convey("When a tick passes", func() {
+			s.run(1)
+			Convey("Then it should be filled up to maxFood", func() {
+				So(s.g.Food.Len(), ShouldEqual, int(s.g.cfg.MaxFood))
+			})
+		})
+
+		Convey("When a player eats some of the food", func() {
+			s.run(1)
+			p := s.join("eater", circle, 1000, 1000)
+			s.g.Food.Each(func(f *Food) {
+				if s.g.Food.Len() > 90 {
+					p.AddMass(p.Cells[0], f.Mass)
+					s.g.SpliceFood(f.ID)
+				}
+			})
+			s.run(1)
+			Convey("Then the food should be topped up again", func() {
+				So(s.g.Food.Len(), ShouldEqual, int(s.g.cfg.MaxFood))
+			})
+		})
+	})
+}
+
+func TestSkirmishGolden(t *testing.T) {
+	Convey("Given four players fighting over a scripted minute", t, func() {
+		s := newSim(42)
+		a := s.join("a", circle, 600, 600)
+		b := s.join("b", square, 1400, 600)
+		c := s.join("c", circle, 600, 1400)
+		d := s.join("d", square, 1400, 1400)
+		s.target(0, a, 300, 300)
+		s.target(0, b, -300, 300)
+		s.target(0, c, 300, -300)
+		s.target(0, d, -300, -300)
+		for tick := 30; tick < 3600; tick += 45 {
+			s.fire(tick, a)
+			s.fire(tick+10, b)
+			s.fire(tick+20, c)
+			s.fire(tick+30, d)
+		}
+		s.sprint(600, b)
+		s.sprint(1200, c)
+		s.target(900, a, -200, 100)
+		s.target(1800, d, 100, -400)
+
+		Convey("When it plays out", func() {
+			s.run(3600)
+			got := s.snapshot()
+			Convey("Then the world should match testdata/skirmish.golden", func() {
+				So(string(got), ShouldEqual, golden("skirmish", got))
+			})
+		})
+	})
+}
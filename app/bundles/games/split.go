@@ -0,0 +1,104 @@
+// Package games handles everything related to our game
+package games
+
+import (
+	"math"
+	"time"
+
+	"github.com/krishamoud/game/app/common/utils"
+)
+
+// mergeReach is how much of two cells' radii must overlap before they merge
+const mergeReach = 1.75
+
+// Split halves every cell of a circle player that has at least twice the
+// starting mass, until the player has LimitSplit cells, and throws the new
+// halves towards the target. The cells keep apart until MergeTimer seconds
+// after the last split and then merge back as they touch.
+func (p *Player) Split(g *Game) {
+	if !p.IsCircle() {
+		return
+	}
+	angle := math.Atan2(p.Target.Y, p.Target.X)
+	split := false
+	for _, cl := range p.Cells {
+		if len(p.Cells) >= g.cfg.LimitSplit {
+			break
+		}
+		if p.splitCell(g, cl, angle) {
+			split = true
+		}
+	}
+	if split {
+		p.LastSplit = g.clock.Now()
+	}
+}
+
+// splitCell halves cl if it has at least twice the starting mass, throwing
+// the other half towards angle. It returns false if cl was too small.
+func (p *Player) splitCell(g *Game, cl *Cell, angle float64) bool {
+	if cl.Mass < g.cfg.DefaultPlayerMass*2 {
+		return false
+	}
+	cl.setMass(cl.Mass / 2)
+	p.PushCells(&Cell{
+		Point:  &utils.Point{X: cl.Point.X, Y: cl.Point.Y},
+		Radius: cl.Radius,
+		Mass:   cl.Mass,
+		Speed:  cl.Speed,
+		impulse: utils.Point{
+			X: math.Cos(angle) * splitSpeed,
+			Y: math.Sin(angle) * splitSpeed,
+		},
+	})
+	return true
+}
+
+// settleCells pushes the player's overlapping cells apart until merge has
+// passed since the last split, and after that merges cells that overlap by
+// enough
+func (p *Player) settleCells(now time.Time, merge time.Duration) {
+	merging := !now.Before(p.LastSplit.Add(merge))
+	for i := 0; i < len(p.Cells); i++ {
+		for j := i + 1; j < len(p.Cells); j++ {
+			a, b := p.Cells[i], p.Cells[j]
+			dx, dy := b.Point.X-a.Point.X, b.Point.Y-a.Point.Y
+			dist := math.Hypot(dx, dy)
+			reach := a.Radius + b.Radius
+			if dist >= reach {
+				continue
+			}
+			if merging {
+				if dist < reach/mergeReach {
+					a.setMass(a.Mass + b.Mass)
+					p.SpliceCells(j)
+					j--
+				}
+				continue
+			}
+			if dist == 0 {
+				dx, dist = 1, 1
+			}
+			push := (reach - dist) / 2 / dist
+			a.Point.X -= dx * push
+			a.Point.Y -= dy * push
+			b.Point.X += dx * push
+			b.Point.Y += dy * push
+		}
+	}
+}
+
+// mergeAll pulls every cell of the player into one at its center
+func (p *Player) mergeAll() {
+	if len(p.Cells) < 2 {
+		return
+	}
+	cl := p.Cells[0]
+	for _, c := range p.Cells[1:] {
+		cl.Mass += c.Mass
+	}
+	cl.setMass(cl.Mass)
+	cl.Point.X, cl.Point.Y = p.Point.X, p.Point.Y
+	cl.impulse = utils.Point{}
+	p.Cells = p.Cells[:1]
+}
@@ -0,0 +1,153 @@
+package games
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Tarliton/collision2d"
+	"github.com/krishamoud/game/app/common/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// grow gives p mass m in a single cell
+func grow(p *Player, m float64) {
+	p.MassTotal, p.MassCurrent = m, m
+	p.Cells[0].setMass(m)
+}
+
+// apart returns how far apart the edges of the cells a and b are
+func apart(a, b *Cell) float64 {
+	return utils.GetDistance(a.Point, b.Point) - a.Radius - b.Radius
+}
+
+func TestSplitSpec(t *testing.T) {
+	Convey("Given a big circle heading right", t, func() {
+		s := newSim(1)
+		s.g.cfg.MergeTimer = 1
+		p := s.join("splitter", circle, 1000, 1000)
+		grow(p, 400)
+		s.target(0, p, 500, 0)
+
+		Convey("When it splits", func() {
+			s.at(1, &SplitCommand{p})
+			s.run(2)
+			Convey("Then it should be two cells sharing its mass", func() {
+				So(len(p.Cells), ShouldEqual, 2)
+				So(p.Cells[0].Mass, ShouldEqual, 200)
+				So(p.Cells[1].Mass, ShouldEqual, 200)
+				So(p.MassTotal, ShouldEqual, 400)
+				So(p.LastSplit, ShouldEqual, s.clock.Now())
+			})
+
+			Convey("Then the new cell should be thrown ahead of the old one", func() {
+				s.run(30)
+				So(p.Cells[1].Point.X-p.Cells[0].Point.X, ShouldBeGreaterThan, p.Cells[0].Radius)
+				So(apart(p.Cells[0], p.Cells[1]), ShouldBeGreaterThanOrEqualTo, -0.001)
+			})
+
+			Convey("Then the cells should merge back once mergeTimer is up", func() {
+				s.target(2, p, 0, 0)
+				s.run(120)
+				So(len(p.Cells), ShouldEqual, 1)
+				So(p.Cells[0].Mass, ShouldEqual, p.MassTotal)
+			})
+
+			Convey("Then food should go to the cell that touches it", func() {
+				s.run(30)
+				far := p.Cells[1]
+				before := far.Mass
+				radius := utils.MassToRadius(10)
+				s.g.PushFood(&Food{
+					Point:  &utils.Point{X: far.Point.X, Y: far.Point.Y},
+					Radius: radius,
+					Mass:   10,
+					Col:    collision2d.NewCircle(collision2d.NewVector(far.Point.X, far.Point.Y), radius),
+				})
+				s.run(1)
+				So(far.Mass, ShouldBeGreaterThanOrEqualTo, before+10)
+				So(far.Mass+p.Cells[0].Mass, ShouldEqual, p.MassTotal)
+			})
+
+			Convey("Then other players should see it once with all of its cells", func() {
+				s.run(30)
+				watcher := s.join("watcher", square, 1100, 1000)
+				watcher.W = 1000
+				var seen []*Player
+				for _, u := range watcher.VisibleCells(s.g) {
+					if u.ID == p.ID {
+						seen = append(seen, u)
+					}
+				}
+				So(seen, ShouldHaveLength, 1)
+				So(seen[0].Cells, ShouldHaveLength, 2)
+			})
+
+			Convey("Then turning into a square should pull it back together", func() {
+				p.ChangeShape(s.clock.Now())
+				So(len(p.Cells), ShouldEqual, 1)
+				So(p.Cells[0].Mass, ShouldEqual, p.MassTotal)
+			})
+		})
+
+		Convey("When it keeps splitting", func() {
+			s.g.cfg.LimitSplit = 3
+			for tick := 1; tick < 5; tick++ {
+				s.at(tick, &SplitCommand{p})
+			}
+			s.run(5)
+			Convey("Then it should stop at limitSplit cells", func() {
+				So(len(p.Cells), ShouldEqual, 3)
+				var total float64
+				for _, cl := range p.Cells {
+					total += cl.Mass
+				}
+				So(total, ShouldEqual, p.MassTotal)
+			})
+		})
+
+		Convey("When it is shot in a cell that split off", func() {
+			s.at(1, &SplitCommand{p})
+			s.run(30)
+			far, near := p.Cells[1], p.Cells[0]
+			farMass, nearMass := far.Mass, near.Mass
+			shooter := s.join("shooter", square, far.Point.X, far.Point.Y+300)
+			s.target(30, shooter, 0, -300)
+			s.fire(31, shooter)
+			s.run(30)
+			Convey("Then only that cell should lose mass", func() {
+				So(far.Mass, ShouldBeLessThan, farMass)
+				So(near.Mass, ShouldEqual, nearMass)
+				So(far.Mass+near.Mass, ShouldAlmostEqual, p.MassTotal)
+				So(p.MassCurrent, ShouldBeLessThan, p.MassTotal)
+			})
+
+			Convey("Then it should bleed from that cell", func() {
+				So(p.MassCurrent, ShouldBeLessThan, p.MassTotal)
+				bled := false
+				s.g.Food.Each(func(f *Food) {
+					if f.PlayerID == p.ID && math.Abs(f.Point.X-far.Point.X) < far.Radius*2 {
+						bled = true
+					}
+				})
+				So(bled, ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given players that can't split", t, func() {
+		s := newSim(1)
+		small := s.join("small", circle, 500, 500)
+		square := s.join("square", square, 1500, 1500)
+		grow(square, 400)
+
+		Convey("When they try", func() {
+			s.at(0, &SplitCommand{small}, &SplitCommand{square})
+			s.run(1)
+			Convey("Then they should stay whole", func() {
+				So(len(small.Cells), ShouldEqual, 1)
+				So(len(square.Cells), ShouldEqual, 1)
+				So(small.LastSplit.IsZero(), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -0,0 +1,85 @@
+// Package games handles everything related to our game
+package games
+
+import (
+	"time"
+
+	"github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/profile"
+)
+
+// Speeds are in map units per second and accelerations in units per second
+// squared, so they don't depend on how often the game ticks
+const (
+	playerSpeed    = 300
+	sprintSpeed    = 435
+	ballisticSpeed = 900
+	bloodSpeed     = 300
+	foodFriction   = 360
+	splitSpeed     = 1200
+	splitFriction  = 2400
+)
+
+// Phases of a tick recorded in Game.Profile
+const (
+	phaseInput      = "input"
+	phaseBots       = "bots"
+	phasePlayers    = "players"
+	phaseBallistics = "ballistics"
+	phaseFood       = "food"
+	phaseIndex      = "index"
+	phaseMass       = "mass"
+	phaseEncode     = "encode"
+	phaseSend       = "send"
+)
+
+// profileWindow is how many seconds of samples Game.Profile keeps
+const profileWindow = 10
+
+// stepper turns wall clock time into a number of fixed size simulation steps.
+// Time left over from one advance carries into the next so the simulation
+// keeps pace with the clock. When the game falls further behind than
+// maxSteps the rest is dropped instead of trying to catch up all at once.
+type stepper struct {
+	step     time.Duration
+	maxSteps int
+	last     time.Time
+	lag      time.Duration
+}
+
+func newStepper(step time.Duration, maxSteps int, now time.Time) *stepper {
+	if maxSteps < 1 {
+		maxSteps = 1
+	}
+	return &stepper{step: step, maxSteps: maxSteps, last: now}
+}
+
+// advance returns how many steps to run to catch up with now, and how many
+// were skipped because there were more than maxSteps
+func (s *stepper) advance(now time.Time) (steps, dropped int) {
+	s.lag += now.Sub(s.last)
+	s.last = now
+	steps = int(s.lag / s.step)
+	if steps > s.maxSteps {
+		dropped = steps - s.maxSteps
+		steps = s.maxSteps
+	}
+	s.lag -= time.Duration(steps+dropped) * s.step
+	return steps, dropped
+}
+
+// newProfile returns a profiler for the phases of a game tick, with the
+// length of a step as its budget
+func newProfile(cfg *conf.Configuration) *profile.Profiler {
+	return profile.New(time.Second/time.Duration(tickRate(cfg)), tickRate(cfg)*profileWindow,
+		phaseInput, phaseBots, phasePlayers, phaseBallistics, phaseFood, phaseIndex, phaseMass,
+		phaseEncode, phaseSend, profile.TickPhase)
+}
+
+// tickRate returns the simulation steps per second from the config
+func tickRate(cfg *conf.Configuration) int {
+	if cfg.TickRate <= 0 {
+		return 60
+	}
+	return cfg.TickRate
+}
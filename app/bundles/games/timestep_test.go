@@ -0,0 +1,46 @@
+package games
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStepperSpec(t *testing.T) {
+	Convey("Given a 10ms stepper that catches up at most 3 steps", t, func() {
+		start := time.Unix(0, 0)
+		s := newStepper(10*time.Millisecond, 3, start)
+
+		Convey("When less than a step has passed", func() {
+			steps, dropped := s.advance(start.Add(4 * time.Millisecond))
+			Convey("Then nothing should run until the rest of the step passes", func() {
+				So(steps, ShouldEqual, 0)
+				So(dropped, ShouldEqual, 0)
+				steps, _ = s.advance(start.Add(12 * time.Millisecond))
+				So(steps, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a tick overran by a couple of steps", func() {
+			steps, dropped := s.advance(start.Add(25 * time.Millisecond))
+			Convey("Then the missed steps should all run", func() {
+				So(steps, ShouldEqual, 2)
+				So(dropped, ShouldEqual, 0)
+				steps, _ = s.advance(start.Add(30 * time.Millisecond))
+				So(steps, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the game stalled for a long time", func() {
+			steps, dropped := s.advance(start.Add(105 * time.Millisecond))
+			Convey("Then only maxSteps should run and the rest be dropped", func() {
+				So(steps, ShouldEqual, 3)
+				So(dropped, ShouldEqual, 7)
+				steps, dropped = s.advance(start.Add(110 * time.Millisecond))
+				So(steps, ShouldEqual, 1)
+				So(dropped, ShouldEqual, 0)
+			})
+		})
+	})
+}
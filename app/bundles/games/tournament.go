@@ -0,0 +1,102 @@
+// Package games handles everything related to our game
+package games
+
+import (
+	"sort"
+	"time"
+
+	"github.com/krishamoud/game/app/common/conf"
+)
+
+// Tournament pits brains against each other in headless games. Every round
+// is a fresh seeded game with a fake clock and no filler bots, where each
+// brain plays the same number of bots for the same number of ticks. Bots that
+// die are put back straight away with a new brain of the same kind, so every
+// brain keeps its numbers up until the round ends.
+type Tournament struct {
+	Brains   []string
+	Bots     int
+	Rounds   int
+	Duration time.Duration
+	Seed     int64
+}
+
+// Score is how one brain did over a tournament
+type Score struct {
+	Brain string `json:"brain"`
+	// Mass is the mean mass of the brain's bots at the end of a round
+	Mass float64 `json:"mass"`
+	// Peak is the most mass one of the brain's bots ever had
+	Peak   float64 `json:"peak"`
+	Deaths int     `json:"deaths"`
+}
+
+// Run plays the tournament on maps configured by cfg and returns the scores
+// from the most mass to the least. The same tournament always returns the
+// same scores.
+func (t *Tournament) Run(cfg *conf.Configuration) ([]Score, error) {
+	scores := make([]Score, len(t.Brains))
+	for i, name := range t.Brains {
+		if _, err := NewBrain(name); err != nil {
+			return nil, err
+		}
+		scores[i].Brain = name
+	}
+	if t.Bots <= 0 {
+		return scores, nil
+	}
+	for round := 0; round < t.Rounds; round++ {
+		t.round(cfg, int64(round), scores)
+	}
+	for i := range scores {
+		if n := float64(t.Rounds * t.Bots); n > 0 {
+			scores[i].Mass /= n
+		}
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Mass > scores[j].Mass
+	})
+	return scores, nil
+}
+
+// round plays one round, adding to scores
+func (t *Tournament) round(cfg *conf.Configuration, round int64, scores []Score) {
+	c := *cfg
+	c.Bots = 0
+	clock := NewFakeClock(time.Unix(0, 0))
+	g := NewGame(&c, WithID("tournament"), WithSeed(t.Seed+round), WithClock(clock))
+	// entrants[n] is a bot of brain n / t.Bots
+	entrants := make([]*Player, len(t.Brains)*t.Bots)
+	enter := func(n int) {
+		name := t.Brains[n/t.Bots]
+		brain, _ := NewBrain(name)
+		entrants[n] = g.AddBot(brain)
+		entrants[n].Name = name
+	}
+	for n := range entrants {
+		enter(n)
+	}
+	g.balanceMass()
+	g.balanceViruses()
+	for ticks := int(t.Duration / g.step); ticks > 0; ticks-- {
+		clock.Advance(g.step)
+		g.MoveLoop()
+		g.balanceMass()
+		g.balanceViruses()
+		for n, p := range entrants {
+			s := &scores[n/t.Bots]
+			if !g.Users.Has(p.ID) {
+				s.Deaths++
+				enter(n)
+				continue
+			}
+			if p.MassTotal > s.Peak {
+				s.Peak = p.MassTotal
+			}
+		}
+	}
+	for n, p := range entrants {
+		scores[n/t.Bots].Mass += p.MassTotal
+	}
+	g.cancel()
+}
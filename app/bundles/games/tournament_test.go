@@ -0,0 +1,53 @@
+package games
+
+import (
+	"testing"
+	"time"
+
+	"github.com/krishamoud/game/app/common/conf"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTournamentSpec(t *testing.T) {
+	Convey("Given a short tournament between the built in brains", t, func() {
+		cfg := conf.Default()
+		cfg.GameWidth, cfg.GameHeight = 1000, 1000
+		cfg.GameMass = 1000
+		cfg.MaxFood = 300
+		tour := &Tournament{
+			Brains:   []string{SeekerBrain, GrazerBrain},
+			Bots:     3,
+			Rounds:   2,
+			Duration: 10 * time.Second,
+			Seed:     1,
+		}
+
+		Convey("When it runs", func() {
+			scores, err := tour.Run(cfg)
+			So(err, ShouldBeNil)
+			Convey("Then every brain should be scored, best first", func() {
+				So(len(scores), ShouldEqual, 2)
+				So(scores[0].Mass, ShouldBeGreaterThanOrEqualTo, scores[1].Mass)
+				for _, s := range scores {
+					So(s.Mass, ShouldBeGreaterThan, 0)
+					So(s.Peak, ShouldBeGreaterThanOrEqualTo, cfg.DefaultPlayerMass)
+				}
+			})
+			Convey("Then running it again should give the same scores", func() {
+				again, _ := tour.Run(cfg)
+				So(again, ShouldResemble, scores)
+			})
+			Convey("Then the config it was given should be left alone", func() {
+				So(cfg.Bots, ShouldEqual, conf.Default().Bots)
+			})
+		})
+
+		Convey("When it names a brain nobody registered", func() {
+			tour.Brains = append(tour.Brains, "nobody")
+			_, err := tour.Run(cfg)
+			Convey("Then it should fail before playing", func() {
+				So(err, ShouldEqual, ErrUnknownBrain)
+			})
+		})
+	})
+}
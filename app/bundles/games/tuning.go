@@ -0,0 +1,46 @@
+// Package games handles everything related to our game
+package games
+
+import (
+	"fmt"
+
+	"github.com/krishamoud/game/app/common/conf"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reload applies the live settings of next to every running game at their
+// next tick boundary and writes an audit log entry for each one that
+// changed. Changes to any other setting are returned as ignored and only
+// take effect on restart. source says where next came from for the audit
+// log.
+func (m *GameManager) Reload(next *conf.Configuration, source string) (changes, ignored []conf.Change, err error) {
+	m.tuning.Lock()
+	defer m.tuning.Unlock()
+	trial := *m.cfg
+	conf.ApplyLive(&trial, next)
+	if err := trial.Validate(); err != nil {
+		return nil, nil, err
+	}
+	changes, ignored = conf.ApplyLive(m.cfg, next)
+	for _, ch := range changes {
+		log.WithFields(log.Fields{
+			"audit":  "config",
+			"source": source,
+			"field":  ch.Field,
+			"old":    ch.Old,
+			"new":    ch.New,
+		}).Info("Config changed")
+	}
+	for _, ch := range ignored {
+		fmt.Println("[WARN] " + ch.Field + " from " + source + " can't change while the server is running, restart to apply it")
+	}
+	return changes, ignored, nil
+}
+
+// Tuning returns a copy of the config games are running with
+func (m *GameManager) Tuning() *conf.Configuration {
+	m.tuning.RLock()
+	defer m.tuning.RUnlock()
+	cfg := *m.cfg
+	return &cfg
+}
@@ -0,0 +1,90 @@
+package games
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/krishamoud/game/app/common/conf"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReloadSpec(t *testing.T) {
+	Convey("Given the config games are running with", t, func() {
+		cfg := conf.Default()
+		m := NewGameManager(cfg)
+		old := *cfg
+
+		Convey("When a live setting is changed", func() {
+			next := m.Tuning()
+			next.MergeTimer = old.MergeTimer + 1
+			changes, _, err := m.Reload(next, "test")
+			Convey("Then games should see it", func() {
+				So(err, ShouldBeNil)
+				So(changes, ShouldHaveLength, 1)
+				So(cfg.MergeTimer, ShouldEqual, old.MergeTimer+1)
+			})
+		})
+
+		Convey("When a game it runs is in the middle of a tick", func() {
+			g, _ := m.Create("a")
+			defer m.Remove("a")
+			next := m.Tuning()
+			next.MergeTimer = old.MergeTimer + 1
+			g.tuning.RLock()
+			reloaded := make(chan struct{})
+			go func() {
+				m.Reload(next, "test")
+				close(reloaded)
+			}()
+			Convey("Then the change should wait for the tick to end", func() {
+				select {
+				case <-reloaded:
+				case <-time.After(50 * time.Millisecond):
+				}
+				So(cfg.MergeTimer, ShouldEqual, old.MergeTimer)
+				g.tuning.RUnlock()
+				<-reloaded
+				So(cfg.MergeTimer, ShouldEqual, old.MergeTimer+1)
+			})
+		})
+
+		Convey("When the change would make the config invalid", func() {
+			next := m.Tuning()
+			next.MaxFood = next.GameMass/next.FoodMass + 1
+			_, _, err := m.Reload(next, "test")
+			Convey("Then nothing should change", func() {
+				So(err, ShouldNotBeNil)
+				So(cfg.MaxFood, ShouldEqual, old.MaxFood)
+			})
+		})
+	})
+}
+
+func TestAdminSpec(t *testing.T) {
+	Convey("Given a request carrying a password", t, func() {
+		defer func(m *GameManager) { Manager = m }(Manager)
+		cfg := conf.Default()
+		Manager = NewGameManager(cfg)
+		r := httptest.NewRequest(http.MethodPost, "/games/config", nil)
+		r.Header.Set("Authorization", "Bearer "+conf.PlaceholderPass)
+
+		Convey("Then it should let it in when it is the adminPass", func() {
+			cfg.AdminPass = "hunter2"
+			r.Header.Set("Authorization", "Bearer hunter2")
+			So(admin(r), ShouldBeTrue)
+		})
+
+		Convey("Then it should not when adminPass is empty", func() {
+			cfg.AdminPass = ""
+			r.Header.Set("Authorization", "Bearer ")
+			So(admin(r), ShouldBeFalse)
+		})
+
+		Convey("Then it should not when adminPass is still the placeholder", func() {
+			cfg.AdminPass = conf.PlaceholderPass
+			So(admin(r), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,169 @@
+// Package games handles everything related to our game
+package games
+
+import (
+	"math"
+
+	"github.com/Tarliton/collision2d"
+	"github.com/krishamoud/game/app/common/protocol"
+	"github.com/krishamoud/game/app/common/spatial"
+	"github.com/krishamoud/game/app/common/utils"
+)
+
+// Virus sits still on the map. Circle cells heavier than it pop into pieces
+// when they touch it, and shooting it feeds it until it splits in two.
+type Virus struct {
+	ID     uint32       `json:"id"`
+	Point  *utils.Point `json:"point"`
+	Radius float64      `json:"radius"`
+	Mass   float64      `json:"mass"`
+	Col    collision2d.Circle
+}
+
+// newVirus returns a virus of mass m centered at point
+func newVirus(point *utils.Point, m float64) *Virus {
+	v := &Virus{Point: point}
+	v.setMass(m)
+	return v
+}
+
+// setMass changes the virus's mass and grows or shrinks it to match
+func (v *Virus) setMass(m float64) {
+	v.Mass = m
+	v.Radius = utils.MassToRadius(m)
+	v.Col = collision2d.NewCircle(collision2d.NewVector(v.Point.X, v.Point.Y), v.Radius)
+}
+
+// rect returns the box around the virus's collider
+func (v *Virus) rect() spatial.Rect {
+	return spatial.Around(v.Point.X, v.Point.Y, v.Radius)
+}
+
+// State returns the virus as sent to clients
+func (v *Virus) State() protocol.Virus {
+	return protocol.Virus{
+		ID:     v.ID,
+		Point:  protocol.Point{X: v.Point.X, Y: v.Point.Y},
+		Radius: v.Radius,
+		Mass:   v.Mass,
+	}
+}
+
+func virusStates(viruses []*Virus) []protocol.Virus {
+	states := make([]protocol.Virus, len(viruses))
+	for i, v := range viruses {
+		states[i] = v.State()
+	}
+	return states
+}
+
+// balanceViruses tops the game up to MaxVirus viruses, or takes the extra
+// ones away if there are more than that
+func (g *Game) balanceViruses() {
+	for g.Viruses.Len() < g.cfg.MaxVirus {
+		g.addVirus()
+	}
+	for g.Viruses.Len() > g.cfg.MaxVirus {
+		id, _, ok := g.Viruses.Last()
+		if !ok {
+			return
+		}
+		g.SpliceVirus(id)
+	}
+}
+
+// addVirus spawns a virus of a random default mass, spread out from the
+// other viruses when VirusUniformDisposition is set and anywhere otherwise
+func (g *Game) addVirus() {
+	m := g.randomIn(g.cfg.Virus.DefaultMass.From, g.cfg.Virus.DefaultMass.To)
+	radius := utils.MassToRadius(m)
+	var position *utils.Point
+	if g.cfg.VirusUniformDisposition {
+		points := make([]*utils.Point, 0, g.Viruses.Len())
+		g.Viruses.Each(func(v *Virus) {
+			points = append(points, v.Point)
+		})
+		position = utils.UniformPositionFrom(points, func() *utils.Point {
+			return g.randomPosition(radius)
+		})
+	} else {
+		position = g.randomPosition(radius)
+	}
+	g.PushVirus(newVirus(position, m))
+}
+
+// feedViruses grows every virus by the mass of the ballistics that hit it.
+// A virus that reaches SplitMass goes back to the smallest default mass and
+// shoots a new virus out the way the last shot was going, unless the game
+// already has MaxVirus of them.
+func (g *Game) feedViruses() {
+	g.Viruses.Each(func(v *Virus) {
+		for _, b := range g.BallisticIndex.QueryRect(v.rect()) {
+			if ok, _ := collision2d.TestCircleCircle(v.Col, b.circle); !ok {
+				continue
+			}
+			g.RemoveBallistic(b.ID)
+			v.setMass(v.Mass + b.Mass)
+			g.VirusIndex.Move(v.ID, v.rect())
+			if v.Mass < float64(g.cfg.Virus.SplitMass) {
+				continue
+			}
+			v.setMass(g.cfg.Virus.DefaultMass.From)
+			g.VirusIndex.Move(v.ID, v.rect())
+			if g.Viruses.Len() >= g.cfg.MaxVirus {
+				continue
+			}
+			reach := v.Radius * 4
+			g.PushVirus(newVirus(&utils.Point{
+				X: math.Max(0, math.Min(g.cfg.GameWidth, v.Point.X+math.Cos(b.Degree)*reach)),
+				Y: math.Max(0, math.Min(g.cfg.GameHeight, v.Point.Y+math.Sin(b.Degree)*reach)),
+			}, g.cfg.Virus.DefaultMass.From))
+		}
+	})
+}
+
+// VirusCollision pops the first cell of a circle player that touches the
+// virus v and is heavier than it, and eats the virus. The virus's mass is
+// lost, not gained. A cell that can't pop, because the player already has
+// LimitSplit cells, passes over the virus and leaves it where it is.
+// VirusCollision returns false if no cell popped.
+func (p *Player) VirusCollision(v *Virus, g *Game) bool {
+	if !p.IsCircle() {
+		return false
+	}
+	for _, cl := range p.Cells {
+		if cl.Mass <= v.Mass {
+			continue
+		}
+		if ok, _ := collision2d.TestCircleCircle(cl.collider, v.Col); ok && p.pop(g, cl) {
+			g.SpliceVirus(v.ID)
+			return true
+		}
+	}
+	return false
+}
+
+// pop bursts the cell cl into as many pieces as LimitSplit and its mass
+// allow, throwing them out all around it. It returns false if cl couldn't
+// split at all.
+func (p *Player) pop(g *Game, cl *Cell) bool {
+	cells := len(p.Cells)
+	pieces := []*Cell{cl}
+	for split := true; split && len(p.Cells) < g.cfg.LimitSplit; {
+		split = false
+		for _, c := range pieces {
+			if len(p.Cells) >= g.cfg.LimitSplit {
+				break
+			}
+			if p.splitCell(g, c, g.rand.Float64()*math.Pi*2) {
+				pieces = append(pieces, p.Cells[len(p.Cells)-1])
+				split = true
+			}
+		}
+	}
+	if len(p.Cells) == cells {
+		return false
+	}
+	p.LastSplit = g.clock.Now()
+	return true
+}
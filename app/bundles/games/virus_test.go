@@ -0,0 +1,154 @@
+package games
+
+import (
+	"testing"
+
+	"github.com/krishamoud/game/app/common/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// virusAt puts a virus of mass m at x, y
+func (s *sim) virusAt(x, y, m float64) *Virus {
+	v := newVirus(&utils.Point{X: x, Y: y}, m)
+	s.g.PushVirus(v)
+	return v
+}
+
+func TestVirusSpec(t *testing.T) {
+	Convey("Given a game that wants viruses", t, func() {
+		s := newSim(1)
+		s.g.cfg.MaxVirus = 5
+
+		Convey("When it ticks", func() {
+			s.run(1)
+			Convey("Then it should have maxVirus of them", func() {
+				So(s.g.Viruses.Len(), ShouldEqual, 5)
+				s.g.Viruses.Each(func(v *Virus) {
+					So(v.Mass, ShouldBeBetweenOrEqual, s.g.cfg.Virus.DefaultMass.From, s.g.cfg.Virus.DefaultMass.To)
+					So(s.g.VirusIndex.QueryRect(v.rect()), ShouldContain, v)
+				})
+			})
+
+			Convey("Then lowering maxVirus should take some away", func() {
+				s.g.cfg.MaxVirus = 2
+				s.run(1)
+				So(s.g.Viruses.Len(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When two of them spread their viruses out with the same seed", func() {
+			placed := func() []utils.Point {
+				s := newSim(7)
+				s.g.cfg.MaxVirus = 5
+				s.g.cfg.VirusUniformDisposition = true
+				s.run(1)
+				var at []utils.Point
+				s.g.Viruses.Each(func(v *Virus) {
+					at = append(at, *v.Point)
+				})
+				return at
+			}
+			Convey("Then they should put them in the same places", func() {
+				So(placed(), ShouldResemble, placed())
+			})
+		})
+	})
+
+	Convey("Given a virus in the middle of the map", t, func() {
+		s := newSim(1)
+		s.g.cfg.MaxVirus = 1
+		v := s.virusAt(1000, 1000, 100)
+
+		Convey("When a big circle runs into it", func() {
+			p := s.join("big", circle, 800, 1000)
+			grow(p, 400)
+			s.target(0, p, 500, 0)
+			s.run(30)
+			Convey("Then the circle should pop into pieces and eat the virus", func() {
+				So(len(p.Cells), ShouldEqual, 8)
+				var total float64
+				for _, cl := range p.Cells {
+					total += cl.Mass
+				}
+				So(total, ShouldEqual, p.MassTotal)
+				So(p.LastSplit.IsZero(), ShouldBeFalse)
+				So(s.g.Viruses.Has(v.ID), ShouldBeFalse)
+			})
+		})
+
+		Convey("When a big circle that can't split any more runs into it", func() {
+			s.g.cfg.LimitSplit = 1
+			s.g.cfg.MaxFood = 0
+			p := s.join("big", circle, 800, 1000)
+			grow(p, 400)
+			mass := p.MassTotal
+			s.target(0, p, 500, 0)
+			s.run(30)
+			Convey("Then the circle should pass over it and the virus should stay", func() {
+				So(len(p.Cells), ShouldEqual, 1)
+				So(p.MassTotal, ShouldEqual, mass)
+				So(p.LastSplit.IsZero(), ShouldBeTrue)
+				So(s.g.Viruses.Has(v.ID), ShouldBeTrue)
+			})
+		})
+
+		Convey("When a small circle and a big square run into it", func() {
+			small := s.join("small", circle, 800, 1000)
+			sq := s.join("square", square, 1200, 1000)
+			grow(sq, 400)
+			s.target(0, small, 500, 0)
+			s.target(0, sq, -500, 0)
+			s.run(30)
+			Convey("Then neither should split and the virus should stay", func() {
+				So(len(small.Cells), ShouldEqual, 1)
+				So(len(sq.Cells), ShouldEqual, 1)
+				So(s.g.Viruses.Has(v.ID), ShouldBeTrue)
+			})
+		})
+
+		Convey("When it is shot", func() {
+			shooter := s.join("shooter", square, 1000, 1300)
+			grow(shooter, 200)
+			s.target(0, shooter, 0, -300)
+			s.fire(1, shooter)
+			s.run(30)
+			Convey("Then it should grow by the shot's mass", func() {
+				So(v.Mass, ShouldAlmostEqual, 120)
+				So(v.Radius, ShouldEqual, utils.MassToRadius(v.Mass))
+				So(s.g.Ballistics.Len(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When it is fed up to splitMass", func() {
+			v.setMass(170)
+			s.g.PushBallistic(NewBallistic(99, ballisticSpeed, 20, &utils.Point{X: 990, Y: 1000}, 0, 1000))
+			Convey("Then it should shrink back and spawn a new virus the way the shot was going", func() {
+				s.g.cfg.MaxVirus = 2
+				s.g.feedViruses()
+				So(v.Mass, ShouldEqual, s.g.cfg.Virus.DefaultMass.From)
+				So(s.g.Viruses.Len(), ShouldEqual, 2)
+				s.g.Viruses.Each(func(n *Virus) {
+					if n != v {
+						So(n.Point.X, ShouldAlmostEqual, 1000+4*v.Radius)
+						So(n.Point.Y, ShouldAlmostEqual, 1000)
+					}
+				})
+			})
+
+			Convey("Then it should not spawn past maxVirus", func() {
+				s.g.feedViruses()
+				So(v.Mass, ShouldEqual, s.g.cfg.Virus.DefaultMass.From)
+				So(s.g.Viruses.Len(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a player stands near it", func() {
+			p := s.join("watcher", circle, 1050, 1000)
+			Convey("Then it should be visible to them", func() {
+				So(p.VisibleViruses(s.g), ShouldContain, v)
+				So(v.State().Mass, ShouldEqual, 100)
+				So(v.State().Radius, ShouldEqual, v.Radius)
+			})
+		})
+	})
+}
@@ -0,0 +1,106 @@
+// Package collection provides an id indexed set of game entities
+package collection
+
+// Collection holds items by id in a slot array. Adding, removing and finding
+// an item are O(1), and items can be added or removed while iterating: removed
+// slots are skipped and reused by later adds, so the slot array only grows to
+// the largest number of items held at once. Free slots at the end of the array
+// are cut off as soon as they appear, so the last slot is always in use.
+type Collection[T any] struct {
+	slots []slot[T]
+	free  []int // may hold slots that were since cut off or reused, Add skips them
+	index map[uint32]int
+}
+
+type slot[T any] struct {
+	id   uint32
+	item T
+	used bool
+}
+
+// New returns an empty collection
+func New[T any]() *Collection[T] {
+	return &Collection[T]{
+		index: make(map[uint32]int),
+	}
+}
+
+// Add stores item under id, replacing any item already stored under it
+func (c *Collection[T]) Add(id uint32, item T) {
+	if i, ok := c.index[id]; ok {
+		c.slots[i].item = item
+		return
+	}
+	i := -1
+	for n := len(c.free); n > 0 && i < 0; n-- {
+		if j := c.free[n-1]; j < len(c.slots) && !c.slots[j].used {
+			i = j
+		}
+		c.free = c.free[:n-1]
+	}
+	if i < 0 {
+		i = len(c.slots)
+		c.slots = append(c.slots, slot[T]{})
+	}
+	c.slots[i] = slot[T]{id: id, item: item, used: true}
+	c.index[id] = i
+}
+
+// Remove deletes the item stored under id and returns it
+func (c *Collection[T]) Remove(id uint32) (T, bool) {
+	var zero T
+	i, ok := c.index[id]
+	if !ok {
+		return zero, false
+	}
+	item := c.slots[i].item
+	c.slots[i] = slot[T]{}
+	c.free = append(c.free, i)
+	delete(c.index, id)
+	n := len(c.slots)
+	for n > 0 && !c.slots[n-1].used {
+		n--
+	}
+	c.slots = c.slots[:n]
+	return item, true
+}
+
+// Get returns the item stored under id
+func (c *Collection[T]) Get(id uint32) (T, bool) {
+	if i, ok := c.index[id]; ok {
+		return c.slots[i].item, true
+	}
+	var zero T
+	return zero, false
+}
+
+// Has returns true if an item is stored under id
+func (c *Collection[T]) Has(id uint32) bool {
+	_, ok := c.index[id]
+	return ok
+}
+
+// Len returns how many items are stored
+func (c *Collection[T]) Len() int {
+	return len(c.index)
+}
+
+// Each calls fn for every item in slot order. Items removed during the walk
+// are skipped if they haven't been reached yet; items added during the walk
+// may or may not be visited.
+func (c *Collection[T]) Each(fn func(item T)) {
+	for i := 0; i < len(c.slots); i++ {
+		if c.slots[i].used {
+			fn(c.slots[i].item)
+		}
+	}
+}
+
+// Last returns the item in the highest used slot, which is the last slot
+func (c *Collection[T]) Last() (uint32, T, bool) {
+	if n := len(c.slots); n > 0 {
+		return c.slots[n-1].id, c.slots[n-1].item, true
+	}
+	var zero T
+	return 0, zero, false
+}
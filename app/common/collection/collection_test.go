@@ -0,0 +1,177 @@
+package collection_test
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/krishamoud/game/app/common/collection"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type item struct {
+	id uint32
+}
+
+func TestCollectionSpec(t *testing.T) {
+	Convey("Given a collection with five items", t, func() {
+		c := collection.New[*item]()
+		for id := uint32(1); id <= 5; id++ {
+			c.Add(id, &item{id})
+		}
+
+		Convey("When an item is removed", func() {
+			it, ok := c.Remove(3)
+			Convey("Then it should be gone", func() {
+				So(ok, ShouldBeTrue)
+				So(it.id, ShouldEqual, 3)
+				So(c.Len(), ShouldEqual, 4)
+				So(c.Has(3), ShouldBeFalse)
+				_, ok = c.Remove(3)
+				So(ok, ShouldBeFalse)
+			})
+			Convey("Then its slot should be reused", func() {
+				c.Add(6, &item{6})
+				var ids []uint32
+				c.Each(func(it *item) {
+					ids = append(ids, it.id)
+				})
+				So(ids, ShouldResemble, []uint32{1, 2, 6, 4, 5})
+			})
+		})
+
+		Convey("When items are removed while iterating", func() {
+			var seen []uint32
+			c.Each(func(it *item) {
+				seen = append(seen, it.id)
+				c.Remove(it.id)
+				c.Remove(4)
+			})
+			Convey("Then removed items should not be visited", func() {
+				So(seen, ShouldResemble, []uint32{1, 2, 3, 5})
+				So(c.Len(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the last item is asked for", func() {
+			c.Remove(5)
+			id, it, ok := c.Last()
+			Convey("Then it should be the item in the highest used slot", func() {
+				So(ok, ShouldBeTrue)
+				So(id, ShouldEqual, 4)
+				So(it.id, ShouldEqual, 4)
+			})
+		})
+
+		Convey("When the items at the end are removed", func() {
+			c.Remove(3)
+			c.Remove(5)
+			c.Remove(4)
+			Convey("Then the item before them should be last", func() {
+				id, _, ok := c.Last()
+				So(ok, ShouldBeTrue)
+				So(id, ShouldEqual, 2)
+			})
+			Convey("Then new items should go after it, once each", func() {
+				c.Add(6, &item{6})
+				c.Add(7, &item{7})
+				c.Add(8, &item{8})
+				var ids []uint32
+				c.Each(func(it *item) {
+					ids = append(ids, it.id)
+				})
+				So(ids, ShouldResemble, []uint32{1, 2, 6, 7, 8})
+				id, _, _ := c.Last()
+				So(id, ShouldEqual, 8)
+			})
+		})
+
+		Convey("When every item is taken off the end", func() {
+			for {
+				id, _, ok := c.Last()
+				if !ok {
+					break
+				}
+				c.Remove(id)
+			}
+			Convey("Then it should be empty and fill up again from the start", func() {
+				So(c.Len(), ShouldEqual, 0)
+				c.Add(9, &item{9})
+				c.Add(10, &item{10})
+				var ids []uint32
+				c.Each(func(it *item) {
+					ids = append(ids, it.id)
+				})
+				So(ids, ShouldResemble, []uint32{9, 10})
+			})
+		})
+
+		Convey("When an item is looked up", func() {
+			it, ok := c.Get(2)
+			_, missing := c.Get(42)
+			Convey("Then it should be found by id", func() {
+				So(ok, ShouldBeTrue)
+				So(it.id, ShouldEqual, 2)
+				So(missing, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+// BenchmarkCollectionRemove removes every item by id, the way players eat food
+func BenchmarkCollectionRemove(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		c := collection.New[*item]()
+		for id := uint32(1); id <= 1000; id++ {
+			c.Add(id, &item{id})
+		}
+		b.StartTimer()
+		for id := uint32(1); id <= 1000; id++ {
+			c.Remove(id)
+		}
+	}
+}
+
+// BenchmarkCollectionLast removes the last item until none are left, the way
+// food and viruses are trimmed down to their limits, after emptying the front
+func BenchmarkCollectionLast(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		c := collection.New[*item]()
+		for id := uint32(1); id <= 10000; id++ {
+			c.Add(id, &item{id})
+		}
+		for id := uint32(1); id <= 5000; id++ {
+			c.Remove(id)
+		}
+		b.StartTimer()
+		for {
+			id, _, ok := c.Last()
+			if !ok {
+				break
+			}
+			c.Remove(id)
+		}
+	}
+}
+
+// BenchmarkListRemove is BenchmarkCollectionRemove with the container/list
+// walk the game used to do
+func BenchmarkListRemove(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		l := list.New()
+		for id := uint32(1); id <= 1000; id++ {
+			l.PushFront(&item{id})
+		}
+		b.StartTimer()
+		for id := uint32(1); id <= 1000; id++ {
+			for e := l.Front(); e != nil; e = e.Next() {
+				if e.Value.(*item).id == id {
+					l.Remove(e)
+					break
+				}
+			}
+		}
+	}
+}
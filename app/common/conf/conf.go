@@ -5,10 +5,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
 )
 
-// AppConf will hold all of our configuration information
-var AppConf = getConf()
+// AppConf will hold all of our configuration information. It starts out as
+// the defaults, main replaces it with Use once it has loaded the config file.
+var AppConf = Default()
+
+// DefaultPath is the config file Load reads when it isn't given one
+const DefaultPath = "./config.json"
+
+// EnvPrefix starts the name of every environment variable ApplyEnv reads
+const EnvPrefix = "GAME"
+
+// PlaceholderPass is the well known password older config files shipped
+// with. It never lets anyone in, an empty adminPass turns the admin
+// endpoints off.
+const PlaceholderPass = "DEFAULT"
 
 // Configuration is the struct that handles the json file with all config info
 type Configuration struct {
@@ -35,6 +52,26 @@ type Configuration struct {
 	MassLossRate             int
 	MinMassLoss              int
 	MergeTimer               int
+	MaxRoomPlayers           int
+	SendQueueSize            int
+	MovePolicy               string
+	MaxSendLag               int
+	WriteTimeout             int
+	KeyframeInterval         int
+	SpatialIndex             string
+	TickRate                 int
+	MaxCatchUpTicks          int
+	ShutdownCountdown        int
+	ShutdownTimeout          int
+	TLSCert                  string
+	TLSKey                   string
+	ReadTimeout              int
+	ServerWriteTimeout       int
+	IdleTimeout              int
+	ConfigPollInterval       int
+	Bots                     int
+	BotBrain                 string
+	BotReactionTime          int
 }
 
 // Virus handles all configuration with regards to viruses
@@ -52,14 +89,161 @@ type DefaultMass struct {
 	To   float64
 }
 
-func getConf() *Configuration {
-	file, err := ioutil.ReadFile("./config.json")
-	if err != nil {
-		fmt.Println(err)
+// Addr returns the host:port the server listens on
+func (c *Configuration) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// TLS returns true when both a certificate and a key are configured, in which
+// case the server only speaks HTTPS and WSS
+func (c *Configuration) TLS() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// Load reads the configuration at path over the defaults, applies GAME_*
+// environment overrides on top and validates the result. An empty path reads
+// DefaultPath if there is one and keeps the defaults if there isn't.
+func Load(path string) (*Configuration, error) {
+	cfg := Default()
+	optional := path == ""
+	if optional {
+		path = DefaultPath
+	}
+	file, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(file, cfg); err != nil {
+			return nil, fmt.Errorf("config %s: %v", path, err)
+		}
+	case !optional || !os.IsNotExist(err):
+		return nil, fmt.Errorf("config %s: %v", path, err)
+	}
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Use makes cfg the configuration every package reads through AppConf
+func Use(cfg *Configuration) {
+	*AppConf = *cfg
+}
+
+// ApplyEnv overrides every field that has an environment variable named
+// GAME_ followed by the upper cased field name, with nested fields joined by
+// underscores, e.g. GAME_GAMEWIDTH or GAME_VIRUS_DEFAULTMASS_FROM. lookup is
+// normally os.LookupEnv.
+func (c *Configuration) ApplyEnv(lookup func(string) (string, bool)) error {
+	return applyEnv(reflect.ValueOf(c).Elem(), EnvPrefix, lookup)
+}
+
+func applyEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		name := prefix + "_" + strings.ToUpper(t.Field(i).Name)
+		if f.Kind() == reflect.Struct {
+			if err := applyEnv(f, name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		var err error
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(raw)
+		case reflect.Int:
+			var n int64
+			n, err = strconv.ParseInt(raw, 10, 0)
+			f.SetInt(n)
+		case reflect.Float64:
+			var n float64
+			n, err = strconv.ParseFloat(raw, 64)
+			f.SetFloat(n)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(raw)
+			f.SetBool(b)
+		}
+		if err != nil {
+			return fmt.Errorf("%s=%q is not a valid %s", name, raw, f.Kind())
+		}
+	}
+	return nil
+}
+
+// ValidationError lists every problem Validate found
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e, "; ")
+}
+
+// Validate returns a ValidationError if any setting would break the game
+func (c *Configuration) Validate() error {
+	var problems ValidationError
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	check(c.Port >= 0 && c.Port <= 65535, "port %d is not between 0 and 65535", c.Port)
+	check((c.TLSCert == "") == (c.TLSKey == ""), "tlsCert and tlsKey must be set together")
+	check(c.GameWidth > 0, "gameWidth must be positive, got %v", c.GameWidth)
+	check(c.GameHeight > 0, "gameHeight must be positive, got %v", c.GameHeight)
+	check(c.FoodMass > 0, "foodMass must be positive, got %v", c.FoodMass)
+	check(c.DefaultPlayerMass > 0, "defaultPlayerMass must be positive, got %v", c.DefaultPlayerMass)
+	check(c.GameMass >= 0, "gameMass can't be negative, got %v", c.GameMass)
+	check(c.MaxFood >= 0, "maxFood can't be negative, got %v", c.MaxFood)
+	if c.FoodMass > 0 {
+		check(c.MaxFood <= c.GameMass/c.FoodMass,
+			"maxFood %v is more than gameMass/foodMass (%v) allows", c.MaxFood, c.GameMass/c.FoodMass)
+	}
+	check(c.Virus.DefaultMass.From > 0, "virus.defaultMass.from must be positive, got %v", c.Virus.DefaultMass.From)
+	check(c.Virus.DefaultMass.From <= c.Virus.DefaultMass.To,
+		"virus.defaultMass.from %v is more than virus.defaultMass.to %v", c.Virus.DefaultMass.From, c.Virus.DefaultMass.To)
+	check(float64(c.Virus.SplitMass) > c.Virus.DefaultMass.To,
+		"virus.splitMass %d must be more than virus.defaultMass.to %v", c.Virus.SplitMass, c.Virus.DefaultMass.To)
+	check(c.MaxVirus >= 0, "maxVirus can't be negative, got %d", c.MaxVirus)
+	check(c.LimitSplit >= 1, "limitSplit must be at least 1, got %d", c.LimitSplit)
+	check(c.MergeTimer >= 0, "mergeTimer can't be negative, got %d", c.MergeTimer)
+	check(c.SlowBase > 1, "slowBase must be more than 1, got %v", c.SlowBase)
+	check(c.NetworkUpdateFactor > 0, "networkUpdateFactor must be positive, got %d", c.NetworkUpdateFactor)
+	check(c.TickRate > 0, "tickRate must be positive, got %d", c.TickRate)
+	check(c.MaxCatchUpTicks > 0, "maxCatchUpTicks must be positive, got %d", c.MaxCatchUpTicks)
+	check(c.MaxRoomPlayers >= 0, "maxRoomPlayers can't be negative, got %d", c.MaxRoomPlayers)
+	check(c.Bots >= 0, "bots can't be negative, got %d", c.Bots)
+	check(c.SendQueueSize > 0, "sendQueueSize must be positive, got %d", c.SendQueueSize)
+	check(c.WriteTimeout > 0, "writeTimeout must be positive, got %d", c.WriteTimeout)
+	check(c.AdminPass != PlaceholderPass,
+		"adminPass can't be %q, set a password of your own or leave it empty", PlaceholderPass)
+	check(c.MovePolicy == "coalesce" || c.MovePolicy == "drop",
+		"movePolicy must be coalesce or drop, got %q", c.MovePolicy)
+	for _, d := range []struct {
+		name string
+		ms   int
+	}{
+		{"maxHeartbeatInterval", c.MaxHeartBeatInterval},
+		{"maxSendLag", c.MaxSendLag},
+		{"shutdownCountdown", c.ShutdownCountdown},
+		{"shutdownTimeout", c.ShutdownTimeout},
+		{"readTimeout", c.ReadTimeout},
+		{"serverWriteTimeout", c.ServerWriteTimeout},
+		{"idleTimeout", c.IdleTimeout},
+		{"configPollInterval", c.ConfigPollInterval},
+		{"botReactionTime", c.BotReactionTime},
+	} {
+		check(d.ms >= 0, "%s can't be negative, got %d", d.name, d.ms)
 	}
-	configuration := &Configuration{}
-	if err := json.Unmarshal(file, &configuration); err != nil {
-		panic(err)
+	if len(problems) > 0 {
+		return problems
 	}
-	return configuration
+	return nil
 }
@@ -0,0 +1,150 @@
+package conf_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krishamoud/game/app/common/conf"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLoadSpec(t *testing.T) {
+	Convey("Given the config file the server ships with", t, func() {
+		cfg, err := conf.Load(filepath.Join("..", "..", "..", conf.DefaultPath))
+		Convey("Then it should load and keep the admin endpoints off", func() {
+			So(err, ShouldBeNil)
+			So(cfg.AdminPass, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given a config file that only sets a few keys", t, func() {
+		dir, _ := ioutil.TempDir("", "conf")
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "config.json")
+		ioutil.WriteFile(path, []byte(`{"port": 4000, "virus": {"splitMass": 200}}`), 0644)
+
+		Convey("When it is loaded", func() {
+			cfg, err := conf.Load(path)
+			Convey("Then the keys it sets should win and the rest should be defaults", func() {
+				So(err, ShouldBeNil)
+				So(cfg.Port, ShouldEqual, 4000)
+				So(cfg.Virus.SplitMass, ShouldEqual, 200)
+				So(cfg.GameWidth, ShouldEqual, conf.Default().GameWidth)
+				So(cfg.Virus.DefaultMass.To, ShouldEqual, conf.Default().Virus.DefaultMass.To)
+			})
+		})
+
+		Convey("When the environment overrides some of it", func() {
+			os.Setenv("GAME_PORT", "5000")
+			os.Setenv("GAME_VIRUS_DEFAULTMASS_FROM", "120")
+			defer os.Unsetenv("GAME_PORT")
+			defer os.Unsetenv("GAME_VIRUS_DEFAULTMASS_FROM")
+			cfg, err := conf.Load(path)
+			Convey("Then the environment should win", func() {
+				So(err, ShouldBeNil)
+				So(cfg.Port, ShouldEqual, 5000)
+				So(cfg.Virus.DefaultMass.From, ShouldEqual, 120)
+			})
+		})
+
+		Convey("When an override isn't the right type", func() {
+			os.Setenv("GAME_GAMEWIDTH", "wide")
+			defer os.Unsetenv("GAME_GAMEWIDTH")
+			_, err := conf.Load(path)
+			Convey("Then it should say which variable is wrong", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "GAME_GAMEWIDTH")
+			})
+		})
+
+		Convey("When the file isn't valid JSON", func() {
+			ioutil.WriteFile(path, []byte(`{"port": `), 0644)
+			_, err := conf.Load(path)
+			Convey("Then it should return an error instead of panicking", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When a path that doesn't exist is asked for", func() {
+			_, err := conf.Load(filepath.Join(dir, "missing.json"))
+			Convey("Then it should return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestValidateSpec(t *testing.T) {
+	Convey("Given the default configuration", t, func() {
+		cfg := conf.Default()
+
+		Convey("Then it should be valid", func() {
+			So(cfg.Validate(), ShouldBeNil)
+		})
+
+		Convey("When several settings make no sense", func() {
+			cfg.GameWidth = -1
+			cfg.MaxFood = cfg.GameMass/cfg.FoodMass + 1
+			cfg.NetworkUpdateFactor = 0
+			err := cfg.Validate()
+			Convey("Then every problem should be reported", func() {
+				So(err, ShouldHaveSameTypeAs, conf.ValidationError{})
+				So(err.(conf.ValidationError), ShouldHaveLength, 3)
+				So(err.Error(), ShouldContainSubstring, "gameWidth")
+				So(err.Error(), ShouldContainSubstring, "maxFood")
+				So(err.Error(), ShouldContainSubstring, "networkUpdateFactor")
+			})
+		})
+
+		Convey("When writeTimeout is 0", func() {
+			cfg.WriteTimeout = 0
+			Convey("Then it should be invalid, since every write would time out", func() {
+				So(cfg.Validate(), ShouldNotBeNil)
+				So(cfg.Validate().Error(), ShouldContainSubstring, "writeTimeout")
+			})
+		})
+
+		invalid := func(name string, set func(), key string) {
+			Convey("When "+name, func() {
+				set()
+				Convey("Then it should be invalid", func() {
+					So(cfg.Validate(), ShouldNotBeNil)
+					So(cfg.Validate().Error(), ShouldContainSubstring, key)
+				})
+			})
+		}
+		invalid("maxVirus is negative", func() { cfg.MaxVirus = -1 }, "maxVirus")
+		invalid("limitSplit is 0", func() { cfg.LimitSplit = 0 }, "limitSplit")
+		invalid("mergeTimer is negative", func() { cfg.MergeTimer = -1 }, "mergeTimer")
+		invalid("viruses split before they are grown", func() { cfg.Virus.SplitMass = 100 }, "virus.splitMass")
+		invalid("adminPass is the placeholder", func() { cfg.AdminPass = conf.PlaceholderPass }, "adminPass")
+	})
+}
+
+func TestApplyLiveSpec(t *testing.T) {
+	Convey("Given a running configuration and an edited copy", t, func() {
+		cfg := conf.Default()
+		next := conf.Default()
+		next.SlowBase = 5
+		next.Virus.SplitMass = 200
+		next.Port = 4000
+
+		Convey("When the live settings are applied", func() {
+			changes, ignored := conf.ApplyLive(cfg, next)
+			Convey("Then only the live settings should change", func() {
+				So(cfg.SlowBase, ShouldEqual, 5)
+				So(cfg.Virus.SplitMass, ShouldEqual, 200)
+				So(cfg.Port, ShouldEqual, conf.Default().Port)
+			})
+			Convey("Then every difference should be reported", func() {
+				So(changes, ShouldResemble, []conf.Change{
+					{Field: "Virus.SplitMass", Old: 180, New: 200},
+					{Field: "SlowBase", Old: 4.5, New: 5.0},
+				})
+				So(ignored, ShouldResemble, []conf.Change{{Field: "Port", Old: 3000, New: 4000}})
+			})
+		})
+	})
+}
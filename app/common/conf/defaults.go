@@ -0,0 +1,58 @@
+// Package conf handles all of the applications configuration management
+package conf
+
+// Default returns the bottom layer of the configuration. Load starts from it
+// before reading the config file and the environment, so it fills in every
+// key the file leaves out, and it is all there is when there is no file, as
+// in package tests.
+func Default() *Configuration {
+	return &Configuration{
+		Host:              "0.0.0.0",
+		Port:              3000,
+		FoodMass:          1,
+		FireFood:          20,
+		LimitSplit:        16,
+		DefaultPlayerMass: 50,
+		Virus: Virus{
+			Fill:        "#33ff33",
+			Stroke:      "#19D119",
+			StrokeWidth: 20,
+			DefaultMass: DefaultMass{
+				From: 100,
+				To:   150,
+			},
+			SplitMass: 180,
+		},
+		GameWidth:                5000,
+		GameHeight:               5000,
+		GameMass:                 20000,
+		MaxFood:                  1000,
+		MaxVirus:                 50,
+		SlowBase:                 4.5,
+		NetworkUpdateFactor:      60,
+		MaxHeartBeatInterval:     5000,
+		FoodUniformDisposition:   true,
+		NewPlayerInitialPosition: "farthest",
+		MassLossRate:             1,
+		MinMassLoss:              50,
+		MergeTimer:               15,
+		MaxRoomPlayers:           50,
+		SendQueueSize:            64,
+		MovePolicy:               "coalesce",
+		MaxSendLag:               120,
+		WriteTimeout:             1000,
+		KeyframeInterval:         300,
+		SpatialIndex:             "grid",
+		TickRate:                 60,
+		MaxCatchUpTicks:          5,
+		ShutdownCountdown:        5000,
+		ShutdownTimeout:          10000,
+		ReadTimeout:              10000,
+		ServerWriteTimeout:       10000,
+		IdleTimeout:              60000,
+		ConfigPollInterval:       2000,
+		Bots:                     10,
+		BotBrain:                 "seeker",
+		BotReactionTime:          200,
+	}
+}
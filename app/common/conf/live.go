@@ -0,0 +1,106 @@
+// Package conf handles all of the applications configuration management
+package conf
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"time"
+)
+
+// LiveFields are the settings that can change while games are running. Every
+// other setting is read once, when the server or a game starts, and only
+// changes on restart. Listing a struct field makes all of its fields live.
+var LiveFields = []string{
+	"FoodMass",
+	"FireFood",
+	"LimitSplit",
+	"DefaultPlayerMass",
+	"Virus",
+	"GameMass",
+	"MaxFood",
+	"MaxVirus",
+	"SlowBase",
+	"LogChat",
+	"FoodUniformDisposition",
+	"VirusUniformDisposition",
+	"MassLossRate",
+	"MinMassLoss",
+	"MergeTimer",
+	"Bots",
+	"BotBrain",
+	"BotReactionTime",
+}
+
+// Change is a setting that differs between two configurations. Nested fields
+// are named with dots, e.g. Virus.SplitMass.
+type Change struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// ApplyLive copies the LiveFields of next into cfg, leaving every other field
+// alone. It returns the live changes that made, and the changes to any other
+// field, which need a restart and are left out.
+func ApplyLive(cfg, next *Configuration) (changes, ignored []Change) {
+	v, n := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(next).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if !isLive(name) {
+			ignored = diff(name, v.Field(i), n.Field(i), ignored)
+			continue
+		}
+		changes = diff(name, v.Field(i), n.Field(i), changes)
+		v.Field(i).Set(n.Field(i))
+	}
+	return changes, ignored
+}
+
+func isLive(name string) bool {
+	for _, f := range LiveFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// diff appends a Change for every leaf field that differs between a and b
+func diff(name string, a, b reflect.Value, changes []Change) []Change {
+	if a.Kind() == reflect.Struct {
+		for i := 0; i < a.NumField(); i++ {
+			changes = diff(name+"."+a.Type().Field(i).Name, a.Field(i), b.Field(i), changes)
+		}
+		return changes
+	}
+	if a.Interface() != b.Interface() {
+		changes = append(changes, Change{Field: name, Old: a.Interface(), New: b.Interface()})
+	}
+	return changes
+}
+
+// Watch checks the modification time of the config file at path every
+// interval until ctx is done, and calls fn with the result of loading it again
+// whenever it changes. A missing file is skipped until it shows up.
+func Watch(ctx context.Context, path string, interval time.Duration, fn func(*Configuration, error)) {
+	var last time.Time
+	if info, err := os.Stat(path); err == nil {
+		last = info.ModTime()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Equal(last) {
+				continue
+			}
+			last = info.ModTime()
+			fn(Load(path))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
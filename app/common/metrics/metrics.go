@@ -0,0 +1,331 @@
+// Package metrics keeps counters, gauges and histograms in process and
+// writes them in the Prometheus text exposition format
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram buckets in seconds suited to tick durations
+var DefaultBuckets = []float64{.0005, .001, .002, .004, .008, .016, .032, .064, .128, .256}
+
+// Default is the registry Handler serves
+var Default = NewRegistry()
+
+// metric is anything a registry can write out
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds named metrics. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+	names   []string
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// register adds m under name, or returns the metric already there
+func (r *Registry) register(name string, m metric) metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.metrics[name]; ok {
+		return old
+	}
+	r.metrics[name] = m
+	r.names = append(r.names, name)
+	sort.Strings(r.names)
+	return m
+}
+
+// WriteText writes every metric in the text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	ms := make([]metric, len(r.names))
+	for i, name := range r.names {
+		ms[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, m := range ms {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry to scrapers
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// Handler serves the Default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// vec holds one series of type S per combination of label values
+type vec[S any] struct {
+	name      string
+	help      string
+	kind      string
+	labels    []string
+	newSeries func() *S
+	mu        sync.Mutex
+	series    map[string]*S
+	values    map[string][]string
+}
+
+func newVec[S any](name, help, kind string, labels []string, newSeries func() *S) *vec[S] {
+	return &vec[S]{
+		name:      name,
+		help:      help,
+		kind:      kind,
+		labels:    labels,
+		newSeries: newSeries,
+		series:    make(map[string]*S),
+		values:    make(map[string][]string),
+	}
+}
+
+// with returns the series for labelValues, creating it if needed. v.mu must
+// be held. Missing label values are empty and extra ones are ignored.
+func (v *vec[S]) with(labelValues []string) *S {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = v.newSeries()
+		v.series[key] = s
+		vals := make([]string, len(v.labels))
+		copy(vals, labelValues)
+		v.values[key] = vals
+	}
+	return s
+}
+
+// DeleteMatching drops every series whose label is value, for example all
+// the series of a game that ended
+func (v *vec[S]) DeleteMatching(label, value string) {
+	i := -1
+	for j, l := range v.labels {
+		if l == label {
+			i = j
+		}
+	}
+	if i < 0 {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for key, vals := range v.values {
+		if vals[i] == value {
+			delete(v.series, key)
+			delete(v.values, key)
+		}
+	}
+}
+
+// each calls fn for every series in label order. v.mu must be held.
+func (v *vec[S]) each(fn func(labels string, s *S)) {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fn(v.labelString(v.values[key], "", ""), v.series[key])
+	}
+}
+
+// labelString formats label pairs, with extra appended if it isn't empty
+func (v *vec[S]) labelString(values []string, extra, extraValue string) string {
+	if len(v.labels) == 0 && extra == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range v.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l)
+		b.WriteString(`="`)
+		b.WriteString(escape(values[i]))
+		b.WriteByte('"')
+	}
+	if extra != "" {
+		if len(v.labels) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra)
+		b.WriteString(`="`)
+		b.WriteString(extraValue)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (v *vec[S]) header(w *bufio.Writer) {
+	w.WriteString("# HELP " + v.name + " " + v.help + "\n")
+	w.WriteString("# TYPE " + v.name + " " + v.kind + "\n")
+}
+
+// value holds the float of a counter or gauge series
+type value struct {
+	v float64
+}
+
+// Counter is a value that only goes up, like messages sent
+type Counter struct {
+	*vec[value]
+}
+
+// NewCounter registers a counter with the given label names in r
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newVec(name, help, "counter", labels, func() *value { return &value{} })}
+	return r.register(name, c).(*Counter)
+}
+
+// Add adds d, which must not be negative, to the series for labelValues
+func (c *Counter) Add(d float64, labelValues ...string) {
+	c.mu.Lock()
+	c.with(labelValues).v += d
+	c.mu.Unlock()
+}
+
+// Inc adds one to the series for labelValues
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	writeValues(c.vec, w)
+}
+
+// Gauge is a value that goes up and down, like players in a game
+type Gauge struct {
+	*vec[value]
+}
+
+// NewGauge registers a gauge with the given label names in r
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newVec(name, help, "gauge", labels, func() *value { return &value{} })}
+	return r.register(name, g).(*Gauge)
+}
+
+// Set sets the series for labelValues to x
+func (g *Gauge) Set(x float64, labelValues ...string) {
+	g.mu.Lock()
+	g.with(labelValues).v = x
+	g.mu.Unlock()
+}
+
+// Add adds d to the series for labelValues
+func (g *Gauge) Add(d float64, labelValues ...string) {
+	g.mu.Lock()
+	g.with(labelValues).v += d
+	g.mu.Unlock()
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	writeValues(g.vec, w)
+}
+
+func writeValues(v *vec[value], w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.header(w)
+	v.each(func(labels string, s *value) {
+		w.WriteString(v.name + labels + " " + format(s.v) + "\n")
+	})
+}
+
+// buckets holds the counts of a histogram series
+type buckets struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Histogram counts observations into buckets, like tick durations
+type Histogram struct {
+	*vec[buckets]
+	bounds []float64
+}
+
+// NewHistogram registers a histogram with the given upper bucket bounds,
+// which must be sorted, and label names in r
+func (r *Registry) NewHistogram(name, help string, bounds []float64, labels ...string) *Histogram {
+	h := &Histogram{bounds: bounds}
+	h.vec = newVec(name, help, "histogram", labels, func() *buckets {
+		return &buckets{counts: make([]uint64, len(bounds))}
+	})
+	return r.register(name, h).(*Histogram)
+}
+
+// Observe adds x to the series for labelValues
+func (h *Histogram) Observe(x float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.with(labelValues)
+	if i := sort.SearchFloat64s(h.bounds, x); i < len(h.bounds) {
+		s.counts[i]++
+	}
+	s.sum += x
+	s.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s, vals := h.series[key], h.values[key]
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += s.counts[i]
+			w.WriteString(h.name + "_bucket" + h.labelString(vals, "le", format(bound)) +
+				" " + strconv.FormatUint(cumulative, 10) + "\n")
+		}
+		w.WriteString(h.name + "_bucket" + h.labelString(vals, "le", "+Inf") +
+			" " + strconv.FormatUint(s.count, 10) + "\n")
+		labels := h.labelString(vals, "", "")
+		w.WriteString(h.name + "_sum" + labels + " " + format(s.sum) + "\n")
+		w.WriteString(h.name + "_count" + labels + " " + strconv.FormatUint(s.count, 10) + "\n")
+	}
+}
+
+func format(x float64) string {
+	switch {
+	case math.IsInf(x, 1):
+		return "+Inf"
+	case math.IsInf(x, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
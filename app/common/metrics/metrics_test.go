@@ -0,0 +1,67 @@
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/krishamoud/game/app/common/metrics"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistrySpec(t *testing.T) {
+	Convey("Given a registry with a counter, a gauge and a histogram", t, func() {
+		r := metrics.NewRegistry()
+		sent := r.NewCounter("game_messages_total", "Messages by type.", "direction", "type")
+		players := r.NewGauge("game_players", "Players in game.", "game")
+		ticks := r.NewHistogram("game_tick_seconds", "Tick durations.", []float64{0.01, 0.1}, "game")
+
+		sent.Inc("out", "serverTellPlayerMove")
+		sent.Add(2, "out", "serverTellPlayerMove")
+		sent.Inc("in", `say "hi"`)
+		players.Set(3, "a")
+		players.Set(5, "b")
+		ticks.Observe(0.005, "a")
+		ticks.Observe(0.05, "a")
+		ticks.Observe(1, "a")
+
+		Convey("When it is written out", func() {
+			var b bytes.Buffer
+			So(r.WriteText(&b), ShouldBeNil)
+			Convey("Then it should be in the text exposition format", func() {
+				So(b.String(), ShouldEqual, `# HELP game_messages_total Messages by type.
+# TYPE game_messages_total counter
+game_messages_total{direction="in",type="say \"hi\""} 1
+game_messages_total{direction="out",type="serverTellPlayerMove"} 3
+# HELP game_players Players in game.
+# TYPE game_players gauge
+game_players{game="a"} 3
+game_players{game="b"} 5
+# HELP game_tick_seconds Tick durations.
+# TYPE game_tick_seconds histogram
+game_tick_seconds_bucket{game="a",le="0.01"} 1
+game_tick_seconds_bucket{game="a",le="0.1"} 2
+game_tick_seconds_bucket{game="a",le="+Inf"} 3
+game_tick_seconds_sum{game="a"} 1.055
+game_tick_seconds_count{game="a"} 3
+`)
+			})
+		})
+
+		Convey("When the series of a game are deleted", func() {
+			players.DeleteMatching("game", "a")
+			var b bytes.Buffer
+			r.WriteText(&b)
+			Convey("Then only the other games should be written", func() {
+				So(b.String(), ShouldNotContainSubstring, `game_players{game="a"}`)
+				So(b.String(), ShouldContainSubstring, `game_players{game="b"} 5`)
+			})
+		})
+
+		Convey("When a metric is registered twice", func() {
+			again := r.NewGauge("game_players", "Players in game.", "game")
+			Convey("Then the first one should be returned", func() {
+				So(again, ShouldEqual, players)
+			})
+		})
+	})
+}
@@ -0,0 +1,168 @@
+// Package profile times the phases of a loop and keeps rolling percentiles
+// of how long each one took
+package profile
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TickPhase is the phase Tick records whole iterations of the loop under
+const TickPhase = "tick"
+
+// Profiler keeps the last Size samples of every phase. It is safe for
+// concurrent use, so the loop can record while Stats is read elsewhere.
+type Profiler struct {
+	Budget   time.Duration
+	Size     int
+	mu       sync.Mutex
+	phases   map[string]*window
+	order    []string
+	overruns uint64
+}
+
+// Stats is a snapshot of a Profiler
+type Stats struct {
+	BudgetMs float64      `json:"budgetMs"`
+	Overruns uint64       `json:"overruns"`
+	Phases   []PhaseStats `json:"phases"`
+}
+
+// PhaseStats summarizes the recent samples of one phase, in milliseconds
+type PhaseStats struct {
+	Name  string  `json:"name"`
+	Count uint64  `json:"count"`
+	Last  float64 `json:"lastMs"`
+	Mean  float64 `json:"meanMs"`
+	P50   float64 `json:"p50Ms"`
+	P90   float64 `json:"p90Ms"`
+	P99   float64 `json:"p99Ms"`
+	Max   float64 `json:"maxMs"`
+}
+
+// window is a ring buffer of the latest samples of a phase
+type window struct {
+	samples []time.Duration
+	next    int
+	count   uint64
+	last    time.Duration
+}
+
+// New returns a profiler that keeps size samples per phase and counts ticks
+// longer than budget as overruns. Phases are listed in Stats in the order
+// given here, then in the order they were first recorded.
+func New(budget time.Duration, size int, phases ...string) *Profiler {
+	p := &Profiler{
+		Budget: budget,
+		Size:   size,
+		phases: make(map[string]*window),
+	}
+	for _, name := range phases {
+		p.phase(name)
+	}
+	return p
+}
+
+// phase returns the window of name, creating it if needed. p.mu must be held.
+func (p *Profiler) phase(name string) *window {
+	w, ok := p.phases[name]
+	if !ok {
+		w = &window{samples: make([]time.Duration, 0, p.Size)}
+		p.phases[name] = w
+		p.order = append(p.order, name)
+	}
+	return w
+}
+
+// Record adds a sample of how long phase took
+func (p *Profiler) Record(phase string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w := p.phase(phase)
+	if len(w.samples) < p.Size {
+		w.samples = append(w.samples, d)
+	} else {
+		w.samples[w.next] = d
+		w.next = (w.next + 1) % p.Size
+	}
+	w.count++
+	w.last = d
+}
+
+// Tick records a whole iteration of the loop and returns true if it took
+// longer than the budget
+func (p *Profiler) Tick(d time.Duration) bool {
+	p.Record(TickPhase, d)
+	if d <= p.Budget {
+		return false
+	}
+	p.mu.Lock()
+	p.overruns++
+	p.mu.Unlock()
+	return true
+}
+
+// Stats returns the percentiles of every phase over its recent samples
+func (p *Profiler) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := Stats{
+		BudgetMs: ms(p.Budget),
+		Overruns: p.overruns,
+		Phases:   make([]PhaseStats, 0, len(p.order)),
+	}
+	for _, name := range p.order {
+		w := p.phases[name]
+		ps := PhaseStats{Name: name, Count: w.count, Last: ms(w.last)}
+		if n := len(w.samples); n > 0 {
+			sorted := append([]time.Duration(nil), w.samples...)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			var sum time.Duration
+			for _, d := range sorted {
+				sum += d
+			}
+			ps.Mean = ms(sum / time.Duration(n))
+			ps.P50 = ms(percentile(sorted, 0.50))
+			ps.P90 = ms(percentile(sorted, 0.90))
+			ps.P99 = ms(percentile(sorted, 0.99))
+			ps.Max = ms(sorted[n-1])
+		}
+		s.Phases = append(s.Phases, ps)
+	}
+	return s
+}
+
+// percentile returns the nearest rank percentile q of sorted
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	i := int(q*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Stopwatch measures consecutive laps
+type Stopwatch struct {
+	last time.Time
+}
+
+// Start returns a stopwatch whose first lap starts now
+func Start() *Stopwatch {
+	return &Stopwatch{last: time.Now()}
+}
+
+// Lap returns the time since the previous lap, or since Start
+func (s *Stopwatch) Lap() time.Duration {
+	now := time.Now()
+	d := now.Sub(s.last)
+	s.last = now
+	return d
+}
@@ -0,0 +1,49 @@
+package profile_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/krishamoud/game/app/common/profile"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProfilerSpec(t *testing.T) {
+	Convey("Given a profiler keeping 100 samples with a 10ms budget", t, func() {
+		p := profile.New(10*time.Millisecond, 100, "input", "players")
+
+		Convey("When a phase took 1 to 200ms", func() {
+			for i := 1; i <= 200; i++ {
+				p.Record("players", time.Duration(i)*time.Millisecond)
+			}
+			s := p.Stats()
+			Convey("Then its percentiles should cover the last 100 samples only", func() {
+				So(s.Phases[1].Name, ShouldEqual, "players")
+				So(s.Phases[1].Count, ShouldEqual, 200)
+				So(s.Phases[1].Last, ShouldEqual, 200)
+				So(s.Phases[1].P50, ShouldEqual, 150)
+				So(s.Phases[1].P90, ShouldEqual, 190)
+				So(s.Phases[1].P99, ShouldEqual, 199)
+				So(s.Phases[1].Max, ShouldEqual, 200)
+				So(s.Phases[1].Mean, ShouldAlmostEqual, 150.5)
+			})
+			Convey("Then phases without samples should still be listed", func() {
+				So(s.Phases[0].Name, ShouldEqual, "input")
+				So(s.Phases[0].Count, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When ticks run over the budget", func() {
+			So(p.Tick(5*time.Millisecond), ShouldBeFalse)
+			So(p.Tick(12*time.Millisecond), ShouldBeTrue)
+			So(p.Tick(30*time.Millisecond), ShouldBeTrue)
+			s := p.Stats()
+			Convey("Then they should be counted as overruns", func() {
+				So(s.Overruns, ShouldEqual, 2)
+				So(s.BudgetMs, ShouldEqual, 10)
+				So(s.Phases[2].Name, ShouldEqual, profile.TickPhase)
+				So(s.Phases[2].Count, ShouldEqual, 3)
+			})
+		})
+	})
+}
@@ -0,0 +1,463 @@
+// Package protocol defines the state updates sent to game clients and the
+// codecs that put them on the wire
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// First byte of a binary frame, saying what it holds
+const (
+	MsgPlayerMove  byte = 1
+	MsgPlayerDelta byte = 2
+)
+
+// quantSteps is the number of positions a uint16 coordinate can take
+const quantSteps = math.MaxUint16
+
+var (
+	// ErrShortFrame is returned when a binary frame ends in the middle of a field
+	ErrShortFrame = errors.New("binary frame too short")
+	// ErrUnknownMessage is returned when a binary frame has an unknown kind
+	ErrUnknownMessage = errors.New("unknown binary message")
+)
+
+// Binary is a compact little endian encoding of updates. Ids, counts, string
+// lengths and hues are varints, masses and sizes are float32 and positions are
+// quantized to a uint16 across the width or height of the map.
+//
+//	frame     = kind:u8 players food ballistics viruses
+//	players   = count:uvarint player*
+//	player    = id:uvarint name:str shape:u8 x:u16 y:u16 w:f32 h:f32
+//	            massTotal:f32 massCurrent:f32 hue:uvarint
+//	            eyeAngle:f32 eyeLength:f32 cells
+//	cells     = count:uvarint (x:u16 y:u16 radius:f32 mass:f32)*
+//	food      = count:uvarint (id:uvarint x:u16 y:u16 hue:uvarint radius:f32 mass:f32)*
+//	ballistics= count:uvarint (id:uvarint playerId:uvarint x:u16 y:u16 speed:f32 radius:f32)*
+//	viruses   = count:uvarint (id:uvarint x:u16 y:u16 radius:f32 mass:f32)*
+//	str       = len:uvarint bytes
+//
+// Deltas use the same player, food, ballistic and virus encodings:
+//
+//	delta     = kind:u8 seq:uvarint baseline:uvarint
+//	            players ids food changes ids ballistics changes ids
+//	            viruses changes ids
+//	ids       = count:uvarint id:uvarint*
+//	changes   = count:uvarint (id:uvarint fields:u8 [x:u16 y:u16]
+//	            [mass:f32 radius:f32] [hue:uvarint])*
+type Binary struct {
+	Width  float64
+	Height float64
+}
+
+// NewBinary returns a binary codec quantizing positions for a map of the
+// given size
+func NewBinary(width, height float64) *Binary {
+	return &Binary{
+		Width:  width,
+		Height: height,
+	}
+}
+
+// Name is the name clients use to ask for the codec
+func (bc *Binary) Name() string {
+	return BinaryCodec
+}
+
+// Binary returns true, binary updates go in binary websocket frames
+func (bc *Binary) Binary() bool {
+	return true
+}
+
+// Encode returns the binary frame for u
+func (bc *Binary) Encode(u *Update) ([]byte, error) {
+	w := &writer{buf: make([]byte, 0, 64+len(u.VisibleFood)*16)}
+	w.byte(MsgPlayerMove)
+	bc.writePlayers(w, u.Players)
+	bc.writeFood(w, u.VisibleFood)
+	bc.writeBallistics(w, u.VisibleBallistics)
+	bc.writeViruses(w, u.VisibleViruses)
+	return w.buf, nil
+}
+
+// Decode reads an update from a binary frame
+func (bc *Binary) Decode(data []byte) (*Update, error) {
+	r := &reader{buf: data}
+	if r.byte() != MsgPlayerMove && r.err == nil {
+		return nil, ErrUnknownMessage
+	}
+	u := &Update{
+		Players:           bc.readPlayers(r),
+		VisibleFood:       bc.readFood(r),
+		VisibleBallistics: bc.readBallistics(r),
+		VisibleViruses:    bc.readViruses(r),
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return u, nil
+}
+
+// EncodeDelta returns the binary frame for d
+func (bc *Binary) EncodeDelta(d *Delta) ([]byte, error) {
+	w := &writer{buf: make([]byte, 0, 64+len(d.Food)*16+len(d.FoodChanged)*8)}
+	w.byte(MsgPlayerDelta)
+	w.uvarint(uint64(d.Seq))
+	w.uvarint(uint64(d.Baseline))
+	bc.writePlayers(w, d.Players)
+	w.ids(d.PlayersGone)
+	bc.writeFood(w, d.Food)
+	bc.writeChanges(w, d.FoodChanged)
+	w.ids(d.FoodGone)
+	bc.writeBallistics(w, d.Ballistics)
+	bc.writeChanges(w, d.BallisticsChanged)
+	w.ids(d.BallisticsGone)
+	bc.writeViruses(w, d.Viruses)
+	bc.writeChanges(w, d.VirusesChanged)
+	w.ids(d.VirusesGone)
+	return w.buf, nil
+}
+
+// DecodeDelta reads a delta from a binary frame
+func (bc *Binary) DecodeDelta(data []byte) (*Delta, error) {
+	r := &reader{buf: data}
+	if r.byte() != MsgPlayerDelta && r.err == nil {
+		return nil, ErrUnknownMessage
+	}
+	d := &Delta{
+		Seq:               uint32(r.uvarint()),
+		Baseline:          uint32(r.uvarint()),
+		Players:           bc.readPlayers(r),
+		PlayersGone:       r.ids(),
+		Food:              bc.readFood(r),
+		FoodChanged:       bc.readChanges(r),
+		FoodGone:          r.ids(),
+		Ballistics:        bc.readBallistics(r),
+		BallisticsChanged: bc.readChanges(r),
+		BallisticsGone:    r.ids(),
+		Viruses:           bc.readViruses(r),
+		VirusesChanged:    bc.readChanges(r),
+		VirusesGone:       r.ids(),
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return d, nil
+}
+
+func (bc *Binary) writePlayers(w *writer, players []Player) {
+	w.uvarint(uint64(len(players)))
+	for i := range players {
+		p := &players[i]
+		w.uvarint(uint64(p.ID))
+		w.string(p.Name)
+		w.shape(p.Shape)
+		bc.point(w, p.Point)
+		w.float32(p.W)
+		w.float32(p.H)
+		w.float32(p.MassTotal)
+		w.float32(p.MassCurrent)
+		w.uvarint(uint64(p.Hue))
+		w.float32(p.EyeAngle)
+		w.float32(p.EyeLength)
+		w.uvarint(uint64(len(p.Cells)))
+		for _, cl := range p.Cells {
+			bc.point(w, cl.Point)
+			w.float32(cl.Radius)
+			w.float32(cl.Mass)
+		}
+	}
+}
+
+func (bc *Binary) readPlayers(r *reader) []Player {
+	players := make([]Player, r.count())
+	for i := range players {
+		p := &players[i]
+		p.ID = uint32(r.uvarint())
+		p.Name = r.string()
+		p.Shape = r.shape()
+		p.Point = bc.readPoint(r)
+		p.W = r.float32()
+		p.H = r.float32()
+		p.MassTotal = r.float32()
+		p.MassCurrent = r.float32()
+		p.Hue = int(r.uvarint())
+		p.EyeAngle = r.float32()
+		p.EyeLength = r.float32()
+		p.Cells = make([]Cell, r.count())
+		for j := range p.Cells {
+			p.Cells[j].Point = bc.readPoint(r)
+			p.Cells[j].Radius = r.float32()
+			p.Cells[j].Mass = r.float32()
+		}
+	}
+	return players
+}
+
+func (bc *Binary) writeFood(w *writer, food []Food) {
+	w.uvarint(uint64(len(food)))
+	for i := range food {
+		f := &food[i]
+		w.uvarint(uint64(f.ID))
+		bc.point(w, f.Point)
+		w.uvarint(uint64(f.Hue))
+		w.float32(f.Radius)
+		w.float32(f.Mass)
+	}
+}
+
+func (bc *Binary) readFood(r *reader) []Food {
+	food := make([]Food, r.count())
+	for i := range food {
+		f := &food[i]
+		f.ID = uint32(r.uvarint())
+		f.Point = bc.readPoint(r)
+		f.Hue = int(r.uvarint())
+		f.Radius = r.float32()
+		f.Mass = r.float32()
+	}
+	return food
+}
+
+func (bc *Binary) writeBallistics(w *writer, ballistics []Ballistic) {
+	w.uvarint(uint64(len(ballistics)))
+	for i := range ballistics {
+		b := &ballistics[i]
+		w.uvarint(uint64(b.ID))
+		w.uvarint(uint64(b.PlayerID))
+		bc.point(w, b.Point)
+		w.float32(b.Speed)
+		w.float32(b.Radius)
+	}
+}
+
+func (bc *Binary) readBallistics(r *reader) []Ballistic {
+	ballistics := make([]Ballistic, r.count())
+	for i := range ballistics {
+		b := &ballistics[i]
+		b.ID = uint32(r.uvarint())
+		b.PlayerID = uint32(r.uvarint())
+		b.Point = bc.readPoint(r)
+		b.Speed = r.float32()
+		b.Radius = r.float32()
+	}
+	return ballistics
+}
+
+func (bc *Binary) writeViruses(w *writer, viruses []Virus) {
+	w.uvarint(uint64(len(viruses)))
+	for i := range viruses {
+		v := &viruses[i]
+		w.uvarint(uint64(v.ID))
+		bc.point(w, v.Point)
+		w.float32(v.Radius)
+		w.float32(v.Mass)
+	}
+}
+
+func (bc *Binary) readViruses(r *reader) []Virus {
+	viruses := make([]Virus, r.count())
+	for i := range viruses {
+		v := &viruses[i]
+		v.ID = uint32(r.uvarint())
+		v.Point = bc.readPoint(r)
+		v.Radius = r.float32()
+		v.Mass = r.float32()
+	}
+	return viruses
+}
+
+func (bc *Binary) writeChanges(w *writer, changes []Change) {
+	w.uvarint(uint64(len(changes)))
+	for i := range changes {
+		ch := &changes[i]
+		w.uvarint(uint64(ch.ID))
+		w.byte(ch.Fields)
+		if ch.Fields&FieldPoint != 0 {
+			bc.point(w, ch.Point)
+		}
+		if ch.Fields&FieldMass != 0 {
+			w.float32(ch.Mass)
+			w.float32(ch.Radius)
+		}
+		if ch.Fields&FieldHue != 0 {
+			w.uvarint(uint64(ch.Hue))
+		}
+	}
+}
+
+func (bc *Binary) readChanges(r *reader) []Change {
+	changes := make([]Change, r.count())
+	for i := range changes {
+		ch := &changes[i]
+		ch.ID = uint32(r.uvarint())
+		ch.Fields = r.byte()
+		if ch.Fields&FieldPoint != 0 {
+			ch.Point = bc.readPoint(r)
+		}
+		if ch.Fields&FieldMass != 0 {
+			ch.Mass = r.float32()
+			ch.Radius = r.float32()
+		}
+		if ch.Fields&FieldHue != 0 {
+			ch.Hue = int(r.uvarint())
+		}
+	}
+	return changes
+}
+
+func (bc *Binary) point(w *writer, p Point) {
+	w.uint16(quantize(p.X, bc.Width))
+	w.uint16(quantize(p.Y, bc.Height))
+}
+
+func (bc *Binary) readPoint(r *reader) Point {
+	x := r.uint16()
+	y := r.uint16()
+	return Point{
+		X: dequantize(x, bc.Width),
+		Y: dequantize(y, bc.Height),
+	}
+}
+
+// quantize maps v from [0, size] onto a uint16, clamping values off the map
+func quantize(v, size float64) uint16 {
+	if size <= 0 || v <= 0 {
+		return 0
+	}
+	if v >= size {
+		return quantSteps
+	}
+	return uint16(math.Round(v / size * quantSteps))
+}
+
+func dequantize(q uint16, size float64) float64 {
+	return float64(q) / quantSteps * size
+}
+
+type writer struct {
+	buf []byte
+}
+
+func (w *writer) byte(b byte) {
+	w.buf = append(w.buf, b)
+}
+
+func (w *writer) uvarint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *writer) uint16(v uint16) {
+	w.buf = binary.LittleEndian.AppendUint16(w.buf, v)
+}
+
+func (w *writer) float32(v float64) {
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, math.Float32bits(float32(v)))
+}
+
+func (w *writer) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *writer) ids(ids []uint32) {
+	w.uvarint(uint64(len(ids)))
+	for _, id := range ids {
+		w.uvarint(uint64(id))
+	}
+}
+
+func (w *writer) shape(s string) {
+	if s == Square {
+		w.byte(1)
+	} else {
+		w.byte(0)
+	}
+}
+
+// reader reads fields from a frame. The first short read sets err and every
+// read after it returns zero values, so callers check err once at the end.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil || len(r.buf) < n {
+		r.err = ErrShortFrame
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) byte() byte {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = ErrShortFrame
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+// count reads a slice length, capped by the bytes left so a corrupt frame
+// can't make us allocate huge slices
+func (r *reader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.buf)) {
+		r.err = ErrShortFrame
+		return 0
+	}
+	return int(n)
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) float32() float64 {
+	if b := r.take(4); b != nil {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+	return 0
+}
+
+func (r *reader) string() string {
+	n := r.count()
+	if b := r.take(n); b != nil {
+		return string(b)
+	}
+	return ""
+}
+
+func (r *reader) ids() []uint32 {
+	ids := make([]uint32, r.count())
+	for i := range ids {
+		ids[i] = uint32(r.uvarint())
+	}
+	return ids
+}
+
+func (r *reader) shape() string {
+	if r.byte() == 1 {
+		return Square
+	}
+	return Circle
+}
@@ -0,0 +1,366 @@
+// Package protocol defines the state updates sent to game clients and the
+// codecs that put them on the wire
+package protocol
+
+// historySize is how many sent snapshots a Tracker keeps to diff against
+const historySize = 64
+
+// Fields that can change on a food pellet, ballistic or virus
+const (
+	FieldPoint uint8 = 1 << iota
+	FieldMass
+	FieldHue
+)
+
+// Change carries the fields of an entity that changed since the baseline.
+// Fields says which of Point, Mass and Radius, or Hue are set.
+type Change struct {
+	ID     uint32  `json:"id"`
+	Fields uint8   `json:"fields"`
+	Point  Point   `json:"point,omitempty"`
+	Mass   float64 `json:"mass,omitempty"`
+	Radius float64 `json:"radius,omitempty"`
+	Hue    int     `json:"hue,omitempty"`
+}
+
+// Delta is the serverTellPlayerDelta payload: what changed between the
+// snapshot the client acknowledged as Baseline and snapshot Seq. A Baseline
+// of 0 is a keyframe, the client should drop everything it knows and start
+// over from the spawns. Players are resent whole whenever anything about them
+// changes.
+type Delta struct {
+	Seq               uint32      `json:"seq"`
+	Baseline          uint32      `json:"baseline"`
+	Players           []Player    `json:"players"`
+	PlayersGone       []uint32    `json:"playersGone"`
+	Food              []Food      `json:"food"`
+	FoodChanged       []Change    `json:"foodChanged"`
+	FoodGone          []uint32    `json:"foodGone"`
+	Ballistics        []Ballistic `json:"ballistics"`
+	BallisticsChanged []Change    `json:"ballisticsChanged"`
+	BallisticsGone    []uint32    `json:"ballisticsGone"`
+	Viruses           []Virus     `json:"viruses"`
+	VirusesChanged    []Change    `json:"virusesChanged"`
+	VirusesGone       []uint32    `json:"virusesGone"`
+}
+
+// Keyframe returns true if the delta doesn't depend on an earlier snapshot
+func (d *Delta) Keyframe() bool {
+	return d.Baseline == 0
+}
+
+// Snapshot is an update indexed by entity id
+type Snapshot struct {
+	Seq        uint32
+	Update     *Update
+	players    map[uint32]int
+	food       map[uint32]int
+	ballistics map[uint32]int
+	viruses    map[uint32]int
+}
+
+// NewSnapshot indexes u
+func NewSnapshot(seq uint32, u *Update) *Snapshot {
+	s := &Snapshot{
+		Seq:        seq,
+		Update:     u,
+		players:    make(map[uint32]int, len(u.Players)),
+		food:       make(map[uint32]int, len(u.VisibleFood)),
+		ballistics: make(map[uint32]int, len(u.VisibleBallistics)),
+		viruses:    make(map[uint32]int, len(u.VisibleViruses)),
+	}
+	for i := range u.Players {
+		s.players[u.Players[i].ID] = i
+	}
+	for i := range u.VisibleFood {
+		s.food[u.VisibleFood[i].ID] = i
+	}
+	for i := range u.VisibleBallistics {
+		s.ballistics[u.VisibleBallistics[i].ID] = i
+	}
+	for i := range u.VisibleViruses {
+		s.viruses[u.VisibleViruses[i].ID] = i
+	}
+	return s
+}
+
+// Diff returns the delta that turns base into s. A nil base gives a keyframe.
+func (s *Snapshot) Diff(base *Snapshot) *Delta {
+	d := &Delta{Seq: s.Seq}
+	if base == nil {
+		base = NewSnapshot(0, &Update{})
+	}
+	d.Baseline = base.Seq
+	u, bu := s.Update, base.Update
+
+	for i := range u.Players {
+		p := &u.Players[i]
+		if j, ok := base.players[p.ID]; !ok || !samePlayer(p, &bu.Players[j]) {
+			d.Players = append(d.Players, *p)
+		}
+	}
+	for i := range bu.Players {
+		if _, ok := s.players[bu.Players[i].ID]; !ok {
+			d.PlayersGone = append(d.PlayersGone, bu.Players[i].ID)
+		}
+	}
+
+	for i := range u.VisibleFood {
+		f := &u.VisibleFood[i]
+		j, ok := base.food[f.ID]
+		if !ok {
+			d.Food = append(d.Food, *f)
+			continue
+		}
+		old := &bu.VisibleFood[j]
+		ch := Change{ID: f.ID}
+		if f.Point != old.Point {
+			ch.Fields |= FieldPoint
+			ch.Point = f.Point
+		}
+		if f.Mass != old.Mass || f.Radius != old.Radius {
+			ch.Fields |= FieldMass
+			ch.Mass = f.Mass
+			ch.Radius = f.Radius
+		}
+		if f.Hue != old.Hue {
+			ch.Fields |= FieldHue
+			ch.Hue = f.Hue
+		}
+		if ch.Fields != 0 {
+			d.FoodChanged = append(d.FoodChanged, ch)
+		}
+	}
+	for i := range bu.VisibleFood {
+		if _, ok := s.food[bu.VisibleFood[i].ID]; !ok {
+			d.FoodGone = append(d.FoodGone, bu.VisibleFood[i].ID)
+		}
+	}
+
+	for i := range u.VisibleBallistics {
+		b := &u.VisibleBallistics[i]
+		j, ok := base.ballistics[b.ID]
+		if !ok {
+			d.Ballistics = append(d.Ballistics, *b)
+			continue
+		}
+		if old := &bu.VisibleBallistics[j]; b.Point != old.Point {
+			d.BallisticsChanged = append(d.BallisticsChanged, Change{
+				ID:     b.ID,
+				Fields: FieldPoint,
+				Point:  b.Point,
+			})
+		}
+	}
+	for i := range bu.VisibleBallistics {
+		if _, ok := s.ballistics[bu.VisibleBallistics[i].ID]; !ok {
+			d.BallisticsGone = append(d.BallisticsGone, bu.VisibleBallistics[i].ID)
+		}
+	}
+
+	for i := range u.VisibleViruses {
+		v := &u.VisibleViruses[i]
+		j, ok := base.viruses[v.ID]
+		if !ok {
+			d.Viruses = append(d.Viruses, *v)
+			continue
+		}
+		old := &bu.VisibleViruses[j]
+		ch := Change{ID: v.ID}
+		if v.Point != old.Point {
+			ch.Fields |= FieldPoint
+			ch.Point = v.Point
+		}
+		if v.Mass != old.Mass || v.Radius != old.Radius {
+			ch.Fields |= FieldMass
+			ch.Mass = v.Mass
+			ch.Radius = v.Radius
+		}
+		if ch.Fields != 0 {
+			d.VirusesChanged = append(d.VirusesChanged, ch)
+		}
+	}
+	for i := range bu.VisibleViruses {
+		if _, ok := s.viruses[bu.VisibleViruses[i].ID]; !ok {
+			d.VirusesGone = append(d.VirusesGone, bu.VisibleViruses[i].ID)
+		}
+	}
+	return d
+}
+
+// Apply returns the update you get by applying d on top of base, which must
+// be the update the client knew as d.Baseline. Keyframes ignore base.
+func Apply(base *Update, d *Delta) *Update {
+	if d.Keyframe() || base == nil {
+		base = &Update{}
+	}
+	u := &Update{}
+
+	gone := set(d.PlayersGone)
+	changed := make(map[uint32]Player, len(d.Players))
+	for _, p := range d.Players {
+		changed[p.ID] = p
+	}
+	for _, p := range base.Players {
+		if gone[p.ID] {
+			continue
+		}
+		if np, ok := changed[p.ID]; ok {
+			p = np
+			delete(changed, p.ID)
+		}
+		u.Players = append(u.Players, p)
+	}
+	for _, p := range d.Players {
+		if _, ok := changed[p.ID]; ok {
+			u.Players = append(u.Players, p)
+		}
+	}
+
+	gone = set(d.FoodGone)
+	changes := changeMap(d.FoodChanged)
+	for _, f := range base.VisibleFood {
+		if gone[f.ID] {
+			continue
+		}
+		if ch, ok := changes[f.ID]; ok {
+			if ch.Fields&FieldPoint != 0 {
+				f.Point = ch.Point
+			}
+			if ch.Fields&FieldMass != 0 {
+				f.Mass = ch.Mass
+				f.Radius = ch.Radius
+			}
+			if ch.Fields&FieldHue != 0 {
+				f.Hue = ch.Hue
+			}
+		}
+		u.VisibleFood = append(u.VisibleFood, f)
+	}
+	u.VisibleFood = append(u.VisibleFood, d.Food...)
+
+	gone = set(d.BallisticsGone)
+	changes = changeMap(d.BallisticsChanged)
+	for _, b := range base.VisibleBallistics {
+		if gone[b.ID] {
+			continue
+		}
+		if ch, ok := changes[b.ID]; ok && ch.Fields&FieldPoint != 0 {
+			b.Point = ch.Point
+		}
+		u.VisibleBallistics = append(u.VisibleBallistics, b)
+	}
+	u.VisibleBallistics = append(u.VisibleBallistics, d.Ballistics...)
+
+	gone = set(d.VirusesGone)
+	changes = changeMap(d.VirusesChanged)
+	for _, v := range base.VisibleViruses {
+		if gone[v.ID] {
+			continue
+		}
+		if ch, ok := changes[v.ID]; ok {
+			if ch.Fields&FieldPoint != 0 {
+				v.Point = ch.Point
+			}
+			if ch.Fields&FieldMass != 0 {
+				v.Mass = ch.Mass
+				v.Radius = ch.Radius
+			}
+		}
+		u.VisibleViruses = append(u.VisibleViruses, v)
+	}
+	u.VisibleViruses = append(u.VisibleViruses, d.Viruses...)
+	return u
+}
+
+// Tracker remembers the snapshots sent to one client so each update can be
+// sent as a delta against the last one the client acknowledged
+type Tracker struct {
+	// KeyframeInterval forces a keyframe after this many deltas, 0 never does
+	KeyframeInterval int
+	seq              uint32
+	acked            uint32
+	sinceKeyframe    int
+	history          [historySize]*Snapshot
+}
+
+// NewTracker returns a tracker that sends a keyframe at least every
+// keyframeInterval updates
+func NewTracker(keyframeInterval int) *Tracker {
+	return &Tracker{KeyframeInterval: keyframeInterval}
+}
+
+// Next records u as the next snapshot and returns the delta to send for it
+func (t *Tracker) Next(u *Update) *Delta {
+	t.seq++
+	if t.seq == 0 {
+		// seq 0 means keyframe, skip it when wrapping around
+		t.seq++
+	}
+	s := NewSnapshot(t.seq, u)
+	base := t.baseline()
+	if base == nil {
+		t.sinceKeyframe = 0
+	} else {
+		t.sinceKeyframe++
+	}
+	t.history[t.seq%historySize] = s
+	return s.Diff(base)
+}
+
+// Ack marks snapshot seq as received by the client. Acks older than the
+// current baseline are ignored.
+func (t *Tracker) Ack(seq uint32) {
+	if seq == 0 || seq > t.seq || seq <= t.acked {
+		return
+	}
+	t.acked = seq
+}
+
+// baseline returns the acknowledged snapshot to diff against, or nil when a
+// keyframe is due
+func (t *Tracker) baseline() *Snapshot {
+	if t.acked == 0 || t.seq-t.acked >= historySize {
+		return nil
+	}
+	if t.KeyframeInterval > 0 && t.sinceKeyframe >= t.KeyframeInterval {
+		return nil
+	}
+	s := t.history[t.acked%historySize]
+	if s == nil || s.Seq != t.acked {
+		return nil
+	}
+	return s
+}
+
+func samePlayer(a, b *Player) bool {
+	if a.Point != b.Point || a.W != b.W || a.H != b.H ||
+		a.MassTotal != b.MassTotal || a.MassCurrent != b.MassCurrent ||
+		a.Hue != b.Hue || a.Shape != b.Shape || a.Name != b.Name ||
+		a.EyeAngle != b.EyeAngle || a.EyeLength != b.EyeLength ||
+		len(a.Cells) != len(b.Cells) {
+		return false
+	}
+	for i := range a.Cells {
+		if a.Cells[i] != b.Cells[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func set(ids []uint32) map[uint32]bool {
+	m := make(map[uint32]bool, len(ids))
+	for _, id := range ids {
+		m[id] = true
+	}
+	return m
+}
+
+func changeMap(changes []Change) map[uint32]Change {
+	m := make(map[uint32]Change, len(changes))
+	for _, ch := range changes {
+		m[ch.ID] = ch
+	}
+	return m
+}
@@ -0,0 +1,97 @@
+package protocol_test
+
+import (
+	"testing"
+
+	"github.com/krishamoud/game/app/common/protocol"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTrackerSpec(t *testing.T) {
+	Convey("Given a tracker with a keyframe every 3 deltas", t, func() {
+		tr := protocol.NewTracker(3)
+		first := sampleUpdate()
+
+		Convey("When the first update is sent", func() {
+			d := tr.Next(first)
+			Convey("Then it should be a keyframe with everything spawned", func() {
+				So(d.Keyframe(), ShouldBeTrue)
+				So(d.Seq, ShouldEqual, 1)
+				So(len(d.Players), ShouldEqual, 2)
+				So(len(d.Food), ShouldEqual, 1)
+				So(len(d.Ballistics), ShouldEqual, 1)
+				So(len(d.Viruses), ShouldEqual, 1)
+				So(protocol.Apply(nil, d), ShouldResemble, first)
+			})
+		})
+
+		Convey("When the client acks and the world changes", func() {
+			tr.Next(first)
+			tr.Ack(1)
+			second := sampleUpdate()
+			second.Players[0].Point.X += 5
+			second.VisibleFood[0].Hue = 40
+			second.VisibleFood = append(second.VisibleFood, protocol.Food{ID: 9, Radius: 10, Mass: 1})
+			second.VisibleBallistics = nil
+			second.VisibleViruses[0].Mass, second.VisibleViruses[0].Radius = 120, 70
+			d := tr.Next(second)
+
+			Convey("Then only the differences should be sent", func() {
+				So(d.Keyframe(), ShouldBeFalse)
+				So(d.Baseline, ShouldEqual, 1)
+				So(d.Seq, ShouldEqual, 2)
+				So(len(d.Players), ShouldEqual, 1)
+				So(d.Players[0].Name, ShouldEqual, "me")
+				So(len(d.Food), ShouldEqual, 1)
+				So(d.Food[0].ID, ShouldEqual, 9)
+				So(len(d.FoodChanged), ShouldEqual, 1)
+				So(d.FoodChanged[0].Fields, ShouldEqual, protocol.FieldHue)
+				So(d.BallisticsGone, ShouldResemble, []uint32{10})
+				So(len(d.Viruses), ShouldEqual, 0)
+				So(len(d.VirusesChanged), ShouldEqual, 1)
+				So(d.VirusesChanged[0].Fields, ShouldEqual, protocol.FieldMass)
+			})
+			Convey("Then applying it to the baseline should give the new update", func() {
+				got := protocol.Apply(first, d)
+				So(got.Players, ShouldResemble, second.Players)
+				So(got.VisibleFood, ShouldResemble, second.VisibleFood)
+				So(len(got.VisibleBallistics), ShouldEqual, 0)
+				So(got.VisibleViruses, ShouldResemble, second.VisibleViruses)
+			})
+			Convey("Then it should survive both codecs", func() {
+				for _, name := range []string{protocol.JSONCodec, protocol.BinaryCodec} {
+					codec, _ := protocol.New(name, 5000, 5000)
+					data, err := codec.EncodeDelta(d)
+					So(err, ShouldBeNil)
+					got, err := codec.DecodeDelta(data)
+					So(err, ShouldBeNil)
+					So(got.Seq, ShouldEqual, d.Seq)
+					So(got.Baseline, ShouldEqual, d.Baseline)
+					So(got.FoodChanged[0].Hue, ShouldEqual, 40)
+					So(got.BallisticsGone, ShouldResemble, d.BallisticsGone)
+					So(got.VirusesChanged[0].Mass, ShouldEqual, 120)
+				}
+			})
+		})
+
+		Convey("When the client never acks", func() {
+			tr.Next(first)
+			d := tr.Next(first)
+			Convey("Then every update should be a keyframe", func() {
+				So(d.Keyframe(), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the keyframe interval passes", func() {
+			var d *protocol.Delta
+			for i := uint32(1); i <= 5; i++ {
+				d = tr.Next(first)
+				tr.Ack(i)
+			}
+			Convey("Then a keyframe should be forced", func() {
+				So(d.Seq, ShouldEqual, 5)
+				So(d.Keyframe(), ShouldBeTrue)
+			})
+		})
+	})
+}
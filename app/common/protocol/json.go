@@ -0,0 +1,40 @@
+// Package protocol defines the state updates sent to game clients and the
+// codecs that put them on the wire
+package protocol
+
+import "encoding/json"
+
+// jsonCodec sends updates as the data of a JSON message
+type jsonCodec struct{}
+
+func (jc *jsonCodec) Name() string {
+	return JSONCodec
+}
+
+func (jc *jsonCodec) Binary() bool {
+	return false
+}
+
+func (jc *jsonCodec) Encode(u *Update) ([]byte, error) {
+	return json.Marshal(u)
+}
+
+func (jc *jsonCodec) Decode(data []byte) (*Update, error) {
+	u := &Update{}
+	if err := json.Unmarshal(data, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (jc *jsonCodec) EncodeDelta(d *Delta) ([]byte, error) {
+	return json.Marshal(d)
+}
+
+func (jc *jsonCodec) DecodeDelta(data []byte) (*Delta, error) {
+	d := &Delta{}
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,110 @@
+// Package protocol defines the state updates sent to game clients and the
+// codecs that put them on the wire
+package protocol
+
+import (
+	"errors"
+)
+
+// Codec names accepted when a client connects
+const (
+	JSONCodec   = "json"
+	BinaryCodec = "binary"
+)
+
+// Shapes a player can have
+const (
+	Circle = "circle"
+	Square = "square"
+)
+
+// ErrUnknownCodec is returned by New for codec names it doesn't know
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// Point is a position in xy space
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Cell is one body of a player
+type Cell struct {
+	Point  Point   `json:"cell"`
+	Radius float64 `json:"radius"`
+	Mass   float64 `json:"mass"`
+}
+
+// Player is a player as seen by another client. ID is 0 and Name is empty for
+// the player receiving the update.
+type Player struct {
+	ID          uint32  `json:"id"`
+	Name        string  `json:"name"`
+	Point       Point   `json:"point"`
+	W           float64 `json:"w"`
+	H           float64 `json:"h"`
+	Cells       []Cell  `json:"cells"`
+	MassTotal   float64 `json:"massTotal"`
+	MassCurrent float64 `json:"massCurrent"`
+	Hue         int     `json:"hue"`
+	Shape       string  `json:"shape"`
+	EyeAngle    float64 `json:"eyeAngle"`
+	EyeLength   float64 `json:"eyeLength"`
+}
+
+// Food is a food pellet or drop of blood
+type Food struct {
+	ID     uint32  `json:"id"`
+	Point  Point   `json:"point"`
+	Hue    int     `json:"hue"`
+	Radius float64 `json:"radius"`
+	Mass   float64 `json:"mass"`
+}
+
+// Ballistic is a projectile in flight
+type Ballistic struct {
+	ID       uint32  `json:"id"`
+	PlayerID uint32  `json:"playerId"`
+	Speed    float64 `json:"speed"`
+	Point    Point   `json:"point"`
+	Radius   float64 `json:"radius"`
+}
+
+// Virus pops circle players bigger than it into pieces
+type Virus struct {
+	ID     uint32  `json:"id"`
+	Point  Point   `json:"point"`
+	Radius float64 `json:"radius"`
+	Mass   float64 `json:"mass"`
+}
+
+// Update is the serverTellPlayerMove payload: everything a player can see
+type Update struct {
+	Players           []Player    `json:"players"`
+	VisibleFood       []Food      `json:"visibleFood"`
+	VisibleBallistics []Ballistic `json:"visibleBallistics"`
+	VisibleViruses    []Virus     `json:"visibleViruses"`
+}
+
+// Codec encodes updates for the wire and decodes them back
+type Codec interface {
+	// Name is the name clients use to ask for the codec
+	Name() string
+	// Binary returns true if encoded updates go in binary websocket frames
+	Binary() bool
+	Encode(u *Update) ([]byte, error)
+	Decode(data []byte) (*Update, error)
+	EncodeDelta(d *Delta) ([]byte, error)
+	DecodeDelta(data []byte) (*Delta, error)
+}
+
+// New returns the codec called name for a map of the given size. An empty
+// name returns the JSON codec.
+func New(name string, width, height float64) (Codec, error) {
+	switch name {
+	case "", JSONCodec:
+		return &jsonCodec{}, nil
+	case BinaryCodec:
+		return NewBinary(width, height), nil
+	}
+	return nil, ErrUnknownCodec
+}
@@ -0,0 +1,121 @@
+package protocol_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/krishamoud/game/app/common/protocol"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func sampleUpdate() *protocol.Update {
+	return &protocol.Update{
+		Players: []protocol.Player{
+			{
+				Name:        "me",
+				Point:       protocol.Point{X: 2500, Y: 1250.5},
+				W:           90,
+				H:           90,
+				Cells:       []protocol.Cell{{Point: protocol.Point{X: 2500, Y: 1250.5}, Radius: 45, Mass: 50}},
+				MassTotal:   50,
+				MassCurrent: 48.5,
+				Hue:         200,
+				Shape:       protocol.Circle,
+				EyeAngle:    1.5,
+				EyeLength:   1,
+			},
+			{
+				ID:        7,
+				Name:      "other",
+				Point:     protocol.Point{X: 0, Y: 5000},
+				W:         60,
+				H:         60,
+				Cells:     []protocol.Cell{},
+				MassTotal: 30,
+				Hue:       359,
+				Shape:     protocol.Square,
+			},
+		},
+		VisibleFood: []protocol.Food{
+			{ID: 8, Point: protocol.Point{X: 10, Y: 4990}, Hue: 12, Radius: 10, Mass: 1},
+		},
+		VisibleBallistics: []protocol.Ballistic{
+			{ID: 10, PlayerID: 7, Speed: 15, Point: protocol.Point{X: 100, Y: 200}, Radius: 8.5},
+		},
+		VisibleViruses: []protocol.Virus{
+			{ID: 11, Point: protocol.Point{X: 3000, Y: 300}, Radius: 64, Mass: 100},
+		},
+	}
+}
+
+// step is the largest error quantizing a coordinate on a 5000 wide map
+var step = 5000.0 / math.MaxUint16
+
+func TestCodecSpec(t *testing.T) {
+	Convey("Given the json and binary codecs for a 5000x5000 map", t, func() {
+		jc, err := protocol.New("", 5000, 5000)
+		So(err, ShouldBeNil)
+		bc, err := protocol.New(protocol.BinaryCodec, 5000, 5000)
+		So(err, ShouldBeNil)
+		u := sampleUpdate()
+
+		Convey("When an update is encoded and decoded with json", func() {
+			data, err := jc.Encode(u)
+			So(err, ShouldBeNil)
+			got, err := jc.Decode(data)
+			Convey("Then it should come back unchanged", func() {
+				So(err, ShouldBeNil)
+				So(jc.Binary(), ShouldBeFalse)
+				So(got, ShouldResemble, u)
+			})
+		})
+
+		Convey("When an update is encoded and decoded with binary", func() {
+			data, err := bc.Encode(u)
+			So(err, ShouldBeNil)
+			got, err := bc.Decode(data)
+			Convey("Then it should match the json update within quantization", func() {
+				So(err, ShouldBeNil)
+				So(bc.Binary(), ShouldBeTrue)
+				So(data[0], ShouldEqual, protocol.MsgPlayerMove)
+				So(len(got.Players), ShouldEqual, 2)
+				So(got.Players[0].ID, ShouldEqual, 0)
+				So(got.Players[0].Name, ShouldEqual, "me")
+				So(got.Players[0].Point.X, ShouldAlmostEqual, 2500, step)
+				So(got.Players[0].Point.Y, ShouldAlmostEqual, 1250.5, step)
+				So(got.Players[0].MassCurrent, ShouldEqual, 48.5)
+				So(got.Players[0].Cells[0].Radius, ShouldEqual, 45)
+				So(got.Players[1].ID, ShouldEqual, 7)
+				So(got.Players[1].Shape, ShouldEqual, protocol.Square)
+				So(got.Players[1].Point.Y, ShouldEqual, 5000)
+				So(got.Players[1].Hue, ShouldEqual, 359)
+				So(got.VisibleFood[0].ID, ShouldEqual, 8)
+				So(got.VisibleFood[0].Point.X, ShouldAlmostEqual, 10, step)
+				So(got.VisibleBallistics[0].PlayerID, ShouldEqual, 7)
+				So(got.VisibleBallistics[0].Radius, ShouldEqual, 8.5)
+				So(got.VisibleViruses[0].ID, ShouldEqual, 11)
+				So(got.VisibleViruses[0].Point.X, ShouldAlmostEqual, 3000, step)
+				So(got.VisibleViruses[0].Mass, ShouldEqual, 100)
+			})
+			Convey("Then it should be smaller than the json encoding", func() {
+				js, _ := jc.Encode(u)
+				So(len(data), ShouldBeLessThan, len(js)/2)
+			})
+		})
+
+		Convey("When a truncated binary frame is decoded", func() {
+			data, _ := bc.Encode(u)
+			_, err := bc.Decode(data[:len(data)-3])
+			Convey("Then it should fail", func() {
+				So(err, ShouldEqual, protocol.ErrShortFrame)
+			})
+		})
+
+		Convey("When an unknown codec is requested", func() {
+			_, err := protocol.New("xml", 5000, 5000)
+			Convey("Then it should fail", func() {
+				So(err, ShouldEqual, protocol.ErrUnknownCodec)
+			})
+		})
+	})
+}
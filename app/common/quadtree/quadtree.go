@@ -1,6 +1,10 @@
 package quadtree
 
 // Quadtree - The quadtree data structure
+//
+// Objects can be removed or moved by ID without rebuilding the tree, so their
+// IDs must be unique. Nodes whose subtree drops to MaxObjects objects or fewer
+// are merged back into a single node.
 type Quadtree struct {
 	Bounds     Bounds
 	MaxObjects int // Maximum objects a node can hold before splitting into 4 subnodes
@@ -8,7 +12,9 @@ type Quadtree struct {
 	Level      int // Depth level, required for subnodes
 	Objects    []Bounds
 	Nodes      []Quadtree
-	Total      int
+	Total      int // Objects in this node and its subnodes
+	parent     *Quadtree
+	index      map[uint32]*Quadtree // Node holding each object, shared by the whole tree
 }
 
 // Bounds - A bounding box with a x y origin and width and height
@@ -17,11 +23,7 @@ type Bounds struct {
 	Y      float64
 	Width  float64
 	Height float64
-	P      string
-	Idx    int
-	Mass   float64
-	ID     string
-	Obj    interface{}
+	ID     uint32
 }
 
 // Split the node into 4 subnodes
@@ -46,6 +48,8 @@ func (qt *Quadtree) Split() {
 		Level:      nextLevel,
 		Objects:    make([]Bounds, 0),
 		Nodes:      make([]Quadtree, 0, 4),
+		parent:     qt,
+		index:      qt.index,
 	})
 
 	//top left node (1)
@@ -61,6 +65,8 @@ func (qt *Quadtree) Split() {
 		Level:      nextLevel,
 		Objects:    make([]Bounds, 0),
 		Nodes:      make([]Quadtree, 0, 4),
+		parent:     qt,
+		index:      qt.index,
 	})
 
 	//bottom left node (2)
@@ -76,6 +82,8 @@ func (qt *Quadtree) Split() {
 		Level:      nextLevel,
 		Objects:    make([]Bounds, 0),
 		Nodes:      make([]Quadtree, 0, 4),
+		parent:     qt,
+		index:      qt.index,
 	})
 
 	//bottom right node (3)
@@ -91,6 +99,8 @@ func (qt *Quadtree) Split() {
 		Level:      nextLevel,
 		Objects:    make([]Bounds, 0),
 		Nodes:      make([]Quadtree, 0, 4),
+		parent:     qt,
+		index:      qt.index,
 	})
 
 }
@@ -137,6 +147,10 @@ func (qt *Quadtree) GetIndex(pRect Bounds) int {
 // it will split and add all objects to their corresponding subnodes.
 func (qt *Quadtree) Insert(pRect Bounds) {
 
+	if qt.index == nil {
+		qt.index = make(map[uint32]*Quadtree)
+	}
+
 	qt.Total++
 
 	i := 0
@@ -155,6 +169,7 @@ func (qt *Quadtree) Insert(pRect Bounds) {
 
 	// If we don't subnodes within the Quadtree
 	qt.Objects = append(qt.Objects, pRect)
+	qt.index[pRect.ID] = qt
 
 	// If total objects is greater than max objects and level is less than max levels
 	if (len(qt.Objects) > qt.MaxObjects) && (qt.Level < qt.MaxLevels) {
@@ -193,7 +208,7 @@ func (qt *Quadtree) Retrieve(pRect Bounds) []Bounds {
 
 	index := qt.GetIndex(pRect)
 
-	returnObjects := qt.Objects // Array with all detected objects
+	returnObjects := append([]Bounds(nil), qt.Objects...) // Array with all detected objects
 
 	//if we have subnodes ...
 	if len(qt.Nodes)-1 > 0 == true {
@@ -216,16 +231,147 @@ func (qt *Quadtree) Retrieve(pRect Bounds) []Bounds {
 
 }
 
-//Clear - Clear the Quadtree
+// Visit - Call fn for every object that could collide with the given object,
+// like Retrieve without building a slice
+func (qt *Quadtree) Visit(pRect Bounds, fn func(Bounds)) {
+
+	for _, o := range qt.Objects {
+		fn(o)
+	}
+
+	//if we have subnodes ...
+	if len(qt.Nodes)-1 > 0 == true {
+
+		index := qt.GetIndex(pRect)
+
+		if index != -1 {
+			qt.Nodes[index].Visit(pRect, fn)
+		} else {
+			for i := 0; i < len(qt.Nodes); i++ {
+				qt.Nodes[i].Visit(pRect, fn)
+			}
+		}
+	}
+
+}
+
+// Clear - Clear the Quadtree
 func (qt *Quadtree) Clear() {
 
 	qt.Objects = []Bounds{}
+	qt.Nodes = []Quadtree{}
+	qt.Total = 0
+	qt.index = make(map[uint32]*Quadtree)
+
+}
+
+// Remove - Remove the object with the given ID, merging nodes that no longer
+// need to be split. Returns false if there is no such object.
+func (qt *Quadtree) Remove(id uint32) bool {
+
+	node, ok := qt.index[id]
+	if !ok {
+		return false
+	}
+
+	for i := range node.Objects {
+		if node.Objects[i].ID == id {
+			node.Objects = append(node.Objects[:i], node.Objects[i+1:]...)
+			break
+		}
+	}
+	delete(qt.index, id)
+
+	for n := node; n != nil; n = n.parent {
+		n.Total--
+	}
+
+	// Merge the highest node whose subtree fits in a single node again
+	var merge *Quadtree
+	for n := node; n != nil; n = n.parent {
+		if len(n.Nodes)-1 > 0 && n.Total <= n.MaxObjects {
+			merge = n
+		}
+	}
+	if merge != nil {
+		merge.merge()
+	}
+
+	return true
+
+}
+
+// Move - Update the bounds of the object with the given ID. Objects that still
+// belong to the same node are updated in place, others are reinserted from
+// the root. Returns false if there is no such object.
+func (qt *Quadtree) Move(id uint32, pRect Bounds) bool {
+
+	node, ok := qt.index[id]
+	if !ok {
+		return false
+	}
+	pRect.ID = id
+
+	if node.holds(pRect) {
+		for i := range node.Objects {
+			if node.Objects[i].ID == id {
+				node.Objects[i] = pRect
+				return true
+			}
+		}
+	}
+
+	root := qt
+	for root.parent != nil {
+		root = root.parent
+	}
+	root.Remove(id)
+	root.Insert(pRect)
+	return true
+
+}
 
-	if len(qt.Nodes)-1 > 0 {
-		for i := 0; i < len(qt.Nodes); i++ {
-			qt.Nodes[i].Clear()
+// holds - Determine if pRect would be stored in this node if inserted from
+// the root
+func (qt *Quadtree) holds(pRect Bounds) bool {
+
+	for n := qt; n.parent != nil; n = n.parent {
+		if n.parent.GetIndex(pRect) != n.childIndex() {
+			return false
+		}
+	}
+
+	return len(qt.Nodes)-1 <= 0 || qt.GetIndex(pRect) == -1
+
+}
+
+// childIndex - Return the index of this node in its parent's Nodes
+func (qt *Quadtree) childIndex() int {
+
+	for i := range qt.parent.Nodes {
+		if &qt.parent.Nodes[i] == qt {
+			return i
+		}
+	}
+	return -1
+
+}
+
+// merge - Pull every object of the subnodes into this node and drop them
+func (qt *Quadtree) merge() {
+
+	var collect func(n *Quadtree)
+	collect = func(n *Quadtree) {
+		for i := range n.Nodes {
+			child := &n.Nodes[i]
+			for _, o := range child.Objects {
+				qt.Objects = append(qt.Objects, o)
+				qt.index[o.ID] = qt
+			}
+			collect(child)
 		}
 	}
+	collect(qt)
 
 	qt.Nodes = []Quadtree{}
 
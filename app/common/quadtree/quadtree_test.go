@@ -0,0 +1,131 @@
+package quadtree_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/krishamoud/game/app/common/quadtree"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newTree() *quadtree.Quadtree {
+	return &quadtree.Quadtree{
+		Bounds:     quadtree.Bounds{Width: 1000, Height: 1000},
+		MaxObjects: 4,
+		MaxLevels:  4,
+		Objects:    make([]quadtree.Bounds, 0),
+		Nodes:      make([]quadtree.Quadtree, 0),
+	}
+}
+
+// box returns a 10x10 object with the given id at x, y
+func box(id uint32, x, y float64) quadtree.Bounds {
+	return quadtree.Bounds{ID: id, X: x, Y: y, Width: 10, Height: 10}
+}
+
+func ids(objects []quadtree.Bounds) map[uint32]bool {
+	m := make(map[uint32]bool, len(objects))
+	for _, o := range objects {
+		m[o.ID] = true
+	}
+	return m
+}
+
+func TestQuadtreeSpec(t *testing.T) {
+	Convey("Given a quadtree with objects in the top left corner", t, func() {
+		qt := newTree()
+		for id := uint32(1); id <= 8; id++ {
+			qt.Insert(box(id, float64(id)*20, float64(id)*20))
+		}
+		So(len(qt.Nodes), ShouldEqual, 4)
+
+		Convey("When an object is removed", func() {
+			ok := qt.Remove(3)
+			Convey("Then it should not be retrieved anymore", func() {
+				So(ok, ShouldBeTrue)
+				So(qt.Total, ShouldEqual, 7)
+				So(ids(qt.Retrieve(box(0, 60, 60))), ShouldNotContainKey, uint32(3))
+				So(qt.Remove(3), ShouldBeFalse)
+			})
+		})
+
+		Convey("When enough objects are removed", func() {
+			for id := uint32(1); id <= 4; id++ {
+				qt.Remove(id)
+			}
+			Convey("Then the nodes should be merged", func() {
+				So(qt.Nodes, ShouldBeEmpty)
+				So(len(qt.Objects), ShouldEqual, 4)
+				So(qt.Total, ShouldEqual, 4)
+			})
+			Convey("Then the merged objects can still be removed", func() {
+				So(qt.Remove(8), ShouldBeTrue)
+				So(len(qt.Objects), ShouldEqual, 3)
+			})
+		})
+
+		Convey("When an object is moved to the other corner", func() {
+			ok := qt.Move(2, box(0, 900, 900))
+			Convey("Then it should be retrieved from its new place only", func() {
+				So(ok, ShouldBeTrue)
+				So(qt.Total, ShouldEqual, 8)
+				So(ids(qt.Retrieve(box(0, 900, 900))), ShouldContainKey, uint32(2))
+				So(ids(qt.Retrieve(box(0, 40, 40))), ShouldNotContainKey, uint32(2))
+			})
+		})
+
+		Convey("When an object is moved a little", func() {
+			qt.Move(1, box(0, 25, 25))
+			Convey("Then it should keep its id and new position", func() {
+				for _, o := range qt.Retrieve(box(0, 25, 25)) {
+					if o.ID == 1 {
+						So(o.X, ShouldEqual, 25)
+					}
+				}
+				So(qt.Move(42, box(0, 1, 1)), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+// scatter returns n objects spread over a 1000x1000 map
+func scatter(n int) []quadtree.Bounds {
+	objects := make([]quadtree.Bounds, n)
+	for i := range objects {
+		objects[i] = box(uint32(i+1), rand.Float64()*990, rand.Float64()*990)
+	}
+	return objects
+}
+
+// BenchmarkRebuild clears and reinserts 1000 static and 100 moving objects,
+// the way the game refreshed its quadtree every tick
+func BenchmarkRebuild(b *testing.B) {
+	objects := scatter(1100)
+	qt := newTree()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt.Clear()
+		for j := range objects {
+			if j < 100 {
+				objects[j].X = rand.Float64() * 990
+			}
+			qt.Insert(objects[j])
+		}
+	}
+}
+
+// BenchmarkMove only moves the 100 moving objects out of 1100
+func BenchmarkMove(b *testing.B) {
+	objects := scatter(1100)
+	qt := newTree()
+	for _, o := range objects {
+		qt.Insert(o)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 100; j++ {
+			objects[j].X = rand.Float64() * 990
+			qt.Move(objects[j].ID, objects[j])
+		}
+	}
+}
@@ -14,6 +14,9 @@ import (
 	// common middleware
 	"github.com/krishamoud/game/app/common/middleware"
 
+	// In process metrics
+	"github.com/krishamoud/game/app/common/metrics"
+
 	"net/http"
 )
 
@@ -41,13 +44,21 @@ func Router() *mux.Router {
 	// s.Handle("/users/{userId}", securedHandlers.ThenFunc(uc.Show)).Methods("GET")
 	// s.Handle("/users/{userId}", commonHandlers.ThenFunc(uc.New)).Methods("OPTIONS")
 
-	// Game connection route
+	// Game routes
+	s.Handle("/games", commonHandlers.ThenFunc(gc.Index)).Methods("GET")
+	s.Handle("/games/{id}/profile", commonHandlers.ThenFunc(gc.Profile)).Methods("GET")
 	s.HandleFunc("/connect", gc.Connect).Methods("GET")
 
+	// Admin routes
+	s.Handle("/admin/config", commonHandlers.ThenFunc(gc.Config)).Methods("POST")
+
 	// Auth Routes
 	// s.Handle("/auth", commonHandlers.ThenFunc(uc.Auth)).Methods("POST")
 	// s.Handle("/auth", commonHandlers.ThenFunc(uc.New)).Methods("OPTIONS")
 
+	// Metrics for Prometheus to scrape
+	r.Handle("/metrics", commonHandlers.Then(metrics.Handler())).Methods("GET")
+
 	// Naked route: only being used for testing purposes at the moment
 	// change home.html to get logs for a certain container
 	r.Handle("/", commonHandlers.ThenFunc(serveHome)).Methods("GET")
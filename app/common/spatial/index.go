@@ -0,0 +1,120 @@
+package spatial
+
+import (
+	"github.com/krishamoud/game/app/common/quadtree"
+	"github.com/krishamoud/game/app/common/spatialgrid"
+)
+
+// broadphase finds the objects that might intersect an area. Both
+// quadtree.Quadtree and spatialgrid.Grid are one.
+type broadphase interface {
+	Insert(pRect quadtree.Bounds)
+	Move(id uint32, pRect quadtree.Bounds) bool
+	Remove(id uint32) bool
+	Visit(pRect quadtree.Bounds, fn func(quadtree.Bounds))
+	Clear()
+}
+
+// index keeps the values in a map and their rectangles in a broadphase,
+// whose candidates it filters down to the ones really intersecting a query
+type index[T any] struct {
+	broadphase broadphase
+	world      Rect
+	entries    map[uint32]*entry[T]
+}
+
+// NewQuadtree returns a quadtree backed index over a width by height map
+func NewQuadtree[T any](width, height float64, maxObjects, maxLevels int) Index[T] {
+	return &index[T]{
+		broadphase: &quadtree.Quadtree{
+			Bounds: quadtree.Bounds{
+				Width:  width,
+				Height: height,
+			},
+			MaxObjects: maxObjects,
+			MaxLevels:  maxLevels,
+			Level:      0,
+			Objects:    make([]quadtree.Bounds, 0),
+			Nodes:      make([]quadtree.Quadtree, 0),
+		},
+		world:   Rect{W: width, H: height},
+		entries: make(map[uint32]*entry[T]),
+	}
+}
+
+// NewGrid returns a uniform grid backed index over a width by height map
+func NewGrid[T any](width, height, cellSize float64) Index[T] {
+	return &index[T]{
+		broadphase: spatialgrid.New(quadtree.Bounds{Width: width, Height: height}, cellSize),
+		world:      Rect{W: width, H: height},
+		entries:    make(map[uint32]*entry[T]),
+	}
+}
+
+func bounds(id uint32, r Rect) quadtree.Bounds {
+	return quadtree.Bounds{ID: id, X: r.X, Y: r.Y, Width: r.W, Height: r.H}
+}
+
+func (idx *index[T]) Insert(id uint32, r Rect, v T) {
+	if e, ok := idx.entries[id]; ok {
+		e.rect = r
+		e.value = v
+		idx.broadphase.Move(id, bounds(id, r))
+		return
+	}
+	idx.entries[id] = &entry[T]{id: id, rect: r, value: v}
+	idx.broadphase.Insert(bounds(id, r))
+}
+
+func (idx *index[T]) Move(id uint32, r Rect) bool {
+	e, ok := idx.entries[id]
+	if !ok {
+		return false
+	}
+	e.rect = r
+	return idx.broadphase.Move(id, bounds(id, r))
+}
+
+func (idx *index[T]) Remove(id uint32) bool {
+	if _, ok := idx.entries[id]; !ok {
+		return false
+	}
+	delete(idx.entries, id)
+	return idx.broadphase.Remove(id)
+}
+
+func (idx *index[T]) Len() int {
+	return len(idx.entries)
+}
+
+func (idx *index[T]) Clear() {
+	idx.broadphase.Clear()
+	idx.entries = make(map[uint32]*entry[T])
+}
+
+// intersecting returns the entries whose rectangle intersects r
+func (idx *index[T]) intersecting(r Rect) []*entry[T] {
+	var found []*entry[T]
+	idx.broadphase.Visit(bounds(0, r), func(o quadtree.Bounds) {
+		if e := idx.entries[o.ID]; e.rect.Intersects(r) {
+			found = append(found, e)
+		}
+	})
+	return found
+}
+
+func (idx *index[T]) QueryRect(r Rect) []T {
+	return values(idx.intersecting(r))
+}
+
+func (idx *index[T]) circle(x, y, radius float64) []*entry[T] {
+	return inCircle(idx.intersecting(Around(x, y, radius)), x, y, radius)
+}
+
+func (idx *index[T]) QueryCircle(x, y, radius float64) []T {
+	return values(idx.circle(x, y, radius))
+}
+
+func (idx *index[T]) Nearest(x, y float64, k int) []T {
+	return nearest(idx.circle, len(idx.entries), idx.world, x, y, k)
+}
@@ -0,0 +1,150 @@
+// Package spatial indexes game entities by position so they can be looked up
+// by area or by distance without walking every entity
+package spatial
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// Index kinds accepted by New
+const (
+	Quadtree = "quadtree"
+	Grid     = "grid"
+)
+
+// Defaults New uses for each kind
+const (
+	DefaultMaxObjects = 200
+	DefaultMaxLevels  = 7
+	DefaultCellSize   = 100
+)
+
+// ErrUnknownIndex is returned by New for index kinds it doesn't know
+var ErrUnknownIndex = errors.New("unknown spatial index")
+
+// Rect is an axis aligned rectangle with its top left corner at X, Y
+type Rect struct {
+	X float64
+	Y float64
+	W float64
+	H float64
+}
+
+// Around returns the square that bounds a circle
+func Around(x, y, radius float64) Rect {
+	return Rect{X: x - radius, Y: y - radius, W: radius * 2, H: radius * 2}
+}
+
+// Intersects returns true if r and o overlap or touch
+func (r Rect) Intersects(o Rect) bool {
+	return r.X <= o.X+o.W && o.X <= r.X+r.W &&
+		r.Y <= o.Y+o.H && o.Y <= r.Y+r.H
+}
+
+// Union returns the smallest rectangle covering both r and o
+func (r Rect) Union(o Rect) Rect {
+	x, y := math.Min(r.X, o.X), math.Min(r.Y, o.Y)
+	return Rect{
+		X: x,
+		Y: y,
+		W: math.Max(r.X+r.W, o.X+o.W) - x,
+		H: math.Max(r.Y+r.H, o.Y+o.H) - y,
+	}
+}
+
+// Distance returns how far x, y is from the closest point of r, 0 inside it
+func (r Rect) Distance(x, y float64) float64 {
+	dx := math.Max(math.Max(r.X-x, 0), x-(r.X+r.W))
+	dy := math.Max(math.Max(r.Y-y, 0), y-(r.Y+r.H))
+	return math.Hypot(dx, dy)
+}
+
+// Index holds values of type T, each with an id and a bounding rectangle.
+// Queries only return values whose rectangle actually intersects the query
+// area. An Index is not safe for concurrent use.
+type Index[T any] interface {
+	// Insert adds v, replacing whatever was stored under id
+	Insert(id uint32, r Rect, v T)
+	// Move changes the rectangle of id, returning false if id isn't there
+	Move(id uint32, r Rect) bool
+	// Remove deletes id, returning false if it wasn't there
+	Remove(id uint32) bool
+	// Len returns how many values are indexed
+	Len() int
+	// Clear removes every value
+	Clear()
+	// QueryRect returns the values intersecting r
+	QueryRect(r Rect) []T
+	// QueryCircle returns the values intersecting the circle at x, y
+	QueryCircle(x, y, radius float64) []T
+	// Nearest returns up to k values ordered by their distance to x, y
+	Nearest(x, y float64, k int) []T
+}
+
+// New returns an empty index of the given kind covering a width by height
+// map. Values outside the map are still indexed, just less efficiently.
+func New[T any](kind string, width, height float64) (Index[T], error) {
+	switch kind {
+	case Quadtree:
+		return NewQuadtree[T](width, height, DefaultMaxObjects, DefaultMaxLevels), nil
+	case Grid:
+		return NewGrid[T](width, height, DefaultCellSize), nil
+	}
+	return nil, ErrUnknownIndex
+}
+
+// entry is an indexed value
+type entry[T any] struct {
+	id    uint32
+	rect  Rect
+	value T
+}
+
+// inCircle returns the entries of candidates intersecting the circle
+func inCircle[T any](candidates []*entry[T], x, y, radius float64) []*entry[T] {
+	found := candidates[:0]
+	for _, e := range candidates {
+		if e.rect.Distance(x, y) <= radius {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func values[T any](entries []*entry[T]) []T {
+	vs := make([]T, len(entries))
+	for i, e := range entries {
+		vs[i] = e.value
+	}
+	return vs
+}
+
+// nearest finds the k entries closest to x, y by querying circles of growing
+// radius until k are found. Everything
+// closer than the k-th entry found is inside the last circle, so the result
+// is exact.
+func nearest[T any](circle func(x, y, radius float64) []*entry[T], n int, world Rect, x, y float64, k int) []T {
+	if k <= 0 || n == 0 {
+		return nil
+	}
+	if k > n {
+		k = n
+	}
+	// Start with a circle that would hold k entries if they were spread
+	// evenly over the map
+	radius := math.Sqrt(world.W*world.H*float64(k)/float64(n)/math.Pi) + 1
+	found := circle(x, y, radius)
+	for len(found) < k && radius < math.MaxFloat64/4 {
+		radius *= 2
+		found = circle(x, y, radius)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].rect.Distance(x, y) < found[j].rect.Distance(x, y)
+	})
+	if len(found) > k {
+		found = found[:k]
+	}
+	return values(found)
+}
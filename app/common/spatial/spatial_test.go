@@ -0,0 +1,184 @@
+package spatial_test
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/krishamoud/game/app/common/spatial"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type thing struct {
+	id   uint32
+	rect spatial.Rect
+}
+
+func sorted(things []*thing) []uint32 {
+	ids := make([]uint32, len(things))
+	for i, t := range things {
+		ids[i] = t.id
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// scatter returns n 10x10 things spread over a 1000x1000 map
+func scatter(r *rand.Rand, n int) []*thing {
+	things := make([]*thing, n)
+	for i := range things {
+		things[i] = &thing{
+			id:   uint32(i + 1),
+			rect: spatial.Rect{X: r.Float64() * 990, Y: r.Float64() * 990, W: 10, H: 10},
+		}
+	}
+	return things
+}
+
+func TestIndexSpec(t *testing.T) {
+	for _, kind := range []string{spatial.Quadtree, spatial.Grid} {
+		Convey("Given a "+kind+" index with three things", t, func() {
+			idx, err := spatial.New[*thing](kind, 1000, 1000)
+			So(err, ShouldBeNil)
+			a := &thing{1, spatial.Rect{X: 10, Y: 10, W: 10, H: 10}}
+			b := &thing{2, spatial.Rect{X: 30, Y: 10, W: 10, H: 10}}
+			far := &thing{3, spatial.Rect{X: 900, Y: 900, W: 10, H: 10}}
+			for _, th := range []*thing{a, b, far} {
+				idx.Insert(th.id, th.rect, th)
+			}
+
+			Convey("When a rectangle is queried", func() {
+				found := idx.QueryRect(spatial.Rect{X: 0, Y: 0, W: 25, H: 25})
+				Convey("Then only the things it overlaps should be returned", func() {
+					So(sorted(found), ShouldResemble, []uint32{1})
+				})
+			})
+
+			Convey("When a circle is queried", func() {
+				found := idx.QueryCircle(25, 15, 5)
+				Convey("Then the things it touches should be returned", func() {
+					So(sorted(found), ShouldResemble, []uint32{1, 2})
+				})
+				Convey("Then the corners of its bounding square should not count", func() {
+					So(idx.QueryCircle(0, 0, 12), ShouldBeEmpty)
+				})
+			})
+
+			Convey("When the nearest things are asked for", func() {
+				found := idx.Nearest(1000, 1000, 2)
+				Convey("Then they should come closest first", func() {
+					So(len(found), ShouldEqual, 2)
+					So(found[0], ShouldEqual, far)
+					So(found[1], ShouldEqual, b)
+					So(len(idx.Nearest(0, 0, 10)), ShouldEqual, 3)
+				})
+			})
+
+			Convey("When a thing is moved and another removed", func() {
+				So(idx.Move(3, spatial.Rect{X: 15, Y: 15, W: 1, H: 1}), ShouldBeTrue)
+				So(idx.Remove(1), ShouldBeTrue)
+				Convey("Then queries should see the change", func() {
+					So(sorted(idx.QueryRect(spatial.Rect{X: 0, Y: 0, W: 25, H: 25})), ShouldResemble, []uint32{3})
+					So(idx.Len(), ShouldEqual, 2)
+					So(idx.Remove(1), ShouldBeFalse)
+					So(idx.Move(1, spatial.Rect{}), ShouldBeFalse)
+				})
+			})
+
+			Convey("When a thing is inserted again under the same id", func() {
+				idx.Insert(1, spatial.Rect{X: 500, Y: 500, W: 10, H: 10}, a)
+				Convey("Then it should replace the old one", func() {
+					So(idx.Len(), ShouldEqual, 3)
+					So(idx.QueryRect(spatial.Rect{X: 0, Y: 0, W: 25, H: 25}), ShouldBeEmpty)
+				})
+			})
+
+			Convey("When it is cleared", func() {
+				idx.Clear()
+				Convey("Then it should be empty", func() {
+					So(idx.Len(), ShouldEqual, 0)
+					So(idx.Nearest(0, 0, 1), ShouldBeEmpty)
+				})
+			})
+		})
+
+		Convey("Given a "+kind+" index with many things", t, func() {
+			r := rand.New(rand.NewSource(1))
+			idx, _ := spatial.New[*thing](kind, 1000, 1000)
+			things := scatter(r, 2000)
+			for _, th := range things {
+				idx.Insert(th.id, th.rect, th)
+			}
+
+			Convey("Then queries should match checking every thing", func() {
+				for i := 0; i < 50; i++ {
+					q := spatial.Rect{X: r.Float64() * 1000, Y: r.Float64() * 1000, W: r.Float64() * 200, H: r.Float64() * 200}
+					x, y, radius := r.Float64()*1000, r.Float64()*1000, r.Float64()*100
+					var inRect, inCircle []*thing
+					for _, th := range things {
+						if th.rect.Intersects(q) {
+							inRect = append(inRect, th)
+						}
+						if th.rect.Distance(x, y) <= radius {
+							inCircle = append(inCircle, th)
+						}
+					}
+					So(sorted(idx.QueryRect(q)), ShouldResemble, sorted(inRect))
+					So(sorted(idx.QueryCircle(x, y, radius)), ShouldResemble, sorted(inCircle))
+
+					sort.Slice(things, func(i, j int) bool {
+						return things[i].rect.Distance(x, y) < things[j].rect.Distance(x, y)
+					})
+					nearest := idx.Nearest(x, y, 5)
+					So(len(nearest), ShouldEqual, 5)
+					So(nearest[4].rect.Distance(x, y), ShouldEqual, things[4].rect.Distance(x, y))
+				}
+			})
+		})
+	}
+
+	Convey("Given an unknown index kind", t, func() {
+		_, err := spatial.New[*thing]("rtree", 1000, 1000)
+		Convey("Then New should fail", func() {
+			So(err, ShouldEqual, spatial.ErrUnknownIndex)
+		})
+	})
+
+	Convey("Given two rectangles apart from each other", t, func() {
+		a := spatial.Rect{X: 0, Y: 10, W: 10, H: 10}
+		b := spatial.Rect{X: 30, Y: 0, W: 5, H: 5}
+		Convey("Then their union should cover both", func() {
+			So(a.Union(b), ShouldResemble, spatial.Rect{X: 0, Y: 0, W: 35, H: 20})
+			So(b.Union(a), ShouldResemble, a.Union(b))
+		})
+	})
+}
+
+// benchmarkQuery moves 100 of 1100 things and queries a screen sized area
+// around each of them, roughly one game tick
+func benchmarkQuery(b *testing.B, kind string) {
+	r := rand.New(rand.NewSource(1))
+	idx, _ := spatial.New[*thing](kind, 1000, 1000)
+	things := scatter(r, 1100)
+	for _, th := range things {
+		idx.Insert(th.id, th.rect, th)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, th := range things[:100] {
+			th.rect.X = r.Float64() * 990
+			idx.Move(th.id, th.rect)
+		}
+		for _, th := range things[:100] {
+			idx.QueryRect(spatial.Rect{X: th.rect.X - 100, Y: th.rect.Y - 60, W: 200, H: 120})
+		}
+	}
+}
+
+func BenchmarkQuadtreeQuery(b *testing.B) {
+	benchmarkQuery(b, spatial.Quadtree)
+}
+
+func BenchmarkGridQuery(b *testing.B) {
+	benchmarkQuery(b, spatial.Grid)
+}
@@ -0,0 +1,174 @@
+// Package spatialgrid is a uniform grid broadphase with the same surface as
+// the quadtree package. For a fixed size map full of objects of similar size
+// it is usually cheaper to update and query than a quadtree.
+package spatialgrid
+
+import (
+	"math"
+
+	"github.com/krishamoud/game/app/common/quadtree"
+)
+
+// Grid - Square cells covering Bounds. An object is stored in every cell it
+// overlaps, objects outside Bounds go in the edge cells. IDs must be unique.
+type Grid struct {
+	Bounds   quadtree.Bounds
+	CellSize float64
+	Total    int // Objects in the grid
+	cols     int
+	rows     int
+	cells    [][]*object
+	objects  map[uint32]*object
+	stamp    uint32
+}
+
+// object - An object and the range of cells it is stored in
+type object struct {
+	bounds         quadtree.Bounds
+	x0, y0, x1, y1 int
+	stamp          uint32 // last Visit that reached this object
+}
+
+// New - Create an empty grid over bounds with cells of cellSize
+func New(bounds quadtree.Bounds, cellSize float64) *Grid {
+	cols := int(math.Ceil(bounds.Width / cellSize))
+	rows := int(math.Ceil(bounds.Height / cellSize))
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return &Grid{
+		Bounds:   bounds,
+		CellSize: cellSize,
+		cols:     cols,
+		rows:     rows,
+		cells:    make([][]*object, cols*rows),
+		objects:  make(map[uint32]*object),
+	}
+}
+
+// cell - The column or row holding v, clamped to the grid
+func (g *Grid) cell(v float64, n int) int {
+	i := math.Floor(v / g.CellSize)
+	if i < 0 || math.IsNaN(i) {
+		return 0
+	}
+	if i > float64(n-1) {
+		return n - 1
+	}
+	return int(i)
+}
+
+// span - The range of cells pRect overlaps
+func (g *Grid) span(pRect quadtree.Bounds) (x0, y0, x1, y1 int) {
+	x, y := pRect.X-g.Bounds.X, pRect.Y-g.Bounds.Y
+	return g.cell(x, g.cols), g.cell(y, g.rows),
+		g.cell(x+pRect.Width, g.cols), g.cell(y+pRect.Height, g.rows)
+}
+
+func (g *Grid) link(o *object) {
+	for y := o.y0; y <= o.y1; y++ {
+		for x := o.x0; x <= o.x1; x++ {
+			i := y*g.cols + x
+			g.cells[i] = append(g.cells[i], o)
+		}
+	}
+}
+
+func (g *Grid) unlink(o *object) {
+	for y := o.y0; y <= o.y1; y++ {
+		for x := o.x0; x <= o.x1; x++ {
+			i := y*g.cols + x
+			cell := g.cells[i]
+			for j := range cell {
+				if cell[j] == o {
+					last := len(cell) - 1
+					cell[j] = cell[last]
+					cell[last] = nil
+					g.cells[i] = cell[:last]
+					break
+				}
+			}
+		}
+	}
+}
+
+// Insert - Insert the object into every cell it overlaps
+func (g *Grid) Insert(pRect quadtree.Bounds) {
+	if g.Move(pRect.ID, pRect) {
+		return
+	}
+	o := &object{bounds: pRect}
+	o.x0, o.y0, o.x1, o.y1 = g.span(pRect)
+	g.objects[pRect.ID] = o
+	g.link(o)
+	g.Total++
+}
+
+// Move - Update the bounds of the object with the given ID. Returns false if
+// there is no such object.
+func (g *Grid) Move(id uint32, pRect quadtree.Bounds) bool {
+	o, ok := g.objects[id]
+	if !ok {
+		return false
+	}
+	pRect.ID = id
+	o.bounds = pRect
+	x0, y0, x1, y1 := g.span(pRect)
+	if x0 == o.x0 && y0 == o.y0 && x1 == o.x1 && y1 == o.y1 {
+		return true
+	}
+	g.unlink(o)
+	o.x0, o.y0, o.x1, o.y1 = x0, y0, x1, y1
+	g.link(o)
+	return true
+}
+
+// Remove - Remove the object with the given ID. Returns false if there is no
+// such object.
+func (g *Grid) Remove(id uint32) bool {
+	o, ok := g.objects[id]
+	if !ok {
+		return false
+	}
+	g.unlink(o)
+	delete(g.objects, id)
+	g.Total--
+	return true
+}
+
+// Visit - Call fn once for every object in the cells pRect overlaps
+func (g *Grid) Visit(pRect quadtree.Bounds, fn func(quadtree.Bounds)) {
+	g.stamp++
+	x0, y0, x1, y1 := g.span(pRect)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			for _, o := range g.cells[y*g.cols+x] {
+				if o.stamp != g.stamp {
+					o.stamp = g.stamp
+					fn(o.bounds)
+				}
+			}
+		}
+	}
+}
+
+// Retrieve - Return all objects that could collide with the given object
+func (g *Grid) Retrieve(pRect quadtree.Bounds) []quadtree.Bounds {
+	var returnObjects []quadtree.Bounds
+	g.Visit(pRect, func(b quadtree.Bounds) {
+		returnObjects = append(returnObjects, b)
+	})
+	return returnObjects
+}
+
+// Clear - Clear the Grid
+func (g *Grid) Clear() {
+	for i := range g.cells {
+		g.cells[i] = nil
+	}
+	g.objects = make(map[uint32]*object)
+	g.Total = 0
+}
@@ -0,0 +1,69 @@
+package spatialgrid_test
+
+import (
+	"testing"
+
+	"github.com/krishamoud/game/app/common/quadtree"
+	"github.com/krishamoud/game/app/common/spatialgrid"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func ids(objects []quadtree.Bounds) []uint32 {
+	found := make([]uint32, len(objects))
+	for i, o := range objects {
+		found[i] = o.ID
+	}
+	return found
+}
+
+func TestGridSpec(t *testing.T) {
+	Convey("Given a grid of 100x100 cells with three objects", t, func() {
+		g := spatialgrid.New(quadtree.Bounds{Width: 1000, Height: 1000}, 100)
+		g.Insert(quadtree.Bounds{ID: 1, X: 10, Y: 10, Width: 10, Height: 10})
+		g.Insert(quadtree.Bounds{ID: 2, X: 90, Y: 90, Width: 20, Height: 20})
+		g.Insert(quadtree.Bounds{ID: 3, X: 950, Y: 950, Width: 10, Height: 10})
+
+		Convey("When the top left cell is retrieved", func() {
+			found := g.Retrieve(quadtree.Bounds{X: 0, Y: 0, Width: 50, Height: 50})
+			Convey("Then the objects touching it should be returned", func() {
+				So(ids(found), ShouldHaveLength, 2)
+				So(ids(found), ShouldContain, uint32(1))
+				So(ids(found), ShouldContain, uint32(2))
+			})
+		})
+
+		Convey("When an area spanning several cells is retrieved", func() {
+			found := g.Retrieve(quadtree.Bounds{X: 50, Y: 50, Width: 100, Height: 100})
+			Convey("Then objects in several of them should come back once", func() {
+				So(ids(found), ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When an object is moved and another removed", func() {
+			So(g.Move(3, quadtree.Bounds{X: 5, Y: 5, Width: 1, Height: 1}), ShouldBeTrue)
+			So(g.Remove(1), ShouldBeTrue)
+			Convey("Then retrieving should see the change", func() {
+				So(ids(g.Retrieve(quadtree.Bounds{Width: 50, Height: 50})), ShouldContain, uint32(3))
+				So(ids(g.Retrieve(quadtree.Bounds{Width: 50, Height: 50})), ShouldNotContain, uint32(1))
+				So(g.Retrieve(quadtree.Bounds{X: 900, Y: 900, Width: 50, Height: 50}), ShouldBeEmpty)
+				So(g.Total, ShouldEqual, 2)
+				So(g.Remove(1), ShouldBeFalse)
+			})
+		})
+
+		Convey("When objects lie outside the grid", func() {
+			g.Insert(quadtree.Bounds{ID: 4, X: -50, Y: 2000, Width: 10, Height: 10})
+			Convey("Then they should be kept in the edge cells", func() {
+				So(ids(g.Retrieve(quadtree.Bounds{X: 0, Y: 950, Width: 10, Height: 10})), ShouldContain, uint32(4))
+			})
+		})
+
+		Convey("When it is cleared", func() {
+			g.Clear()
+			Convey("Then nothing should be retrieved", func() {
+				So(g.Retrieve(quadtree.Bounds{Width: 1000, Height: 1000}), ShouldBeEmpty)
+				So(g.Total, ShouldEqual, 0)
+			})
+		})
+	})
+}
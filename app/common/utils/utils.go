@@ -9,12 +9,8 @@ import (
 	"math/rand"
 	"strconv"
 	"time"
-
-	"github.com/krishamoud/game/app/common/conf"
 )
 
-var cfg = conf.AppConf
-
 // Point is a position in xy space
 type Point struct {
 	X      float64 `json:"x"`
@@ -73,10 +69,11 @@ func RandomInRange(from, to float64) float64 {
 	return f2
 }
 
-// RandomPosition generates a random position within the field of play
-func RandomPosition(radius float64) *Point {
-	x := RandomInRange(0, cfg.GameWidth)
-	y := RandomInRange(0, cfg.GameHeight)
+// RandomPosition generates a random position within a width by height field
+// of play
+func RandomPosition(radius, width, height float64) *Point {
+	x := RandomInRange(0, width)
+	y := RandomInRange(0, height)
 	return &Point{
 		X: x,
 		Y: y,
@@ -92,16 +89,26 @@ func Log(n, base float64) float64 {
 }
 
 // UniformPosition distributes returns a single point that is evenly distributed
-func UniformPosition(points []*Point, radius float64) *Point {
+// within a width by height field of play
+func UniformPosition(points []*Point, radius, width, height float64) *Point {
+	return UniformPositionFrom(points, func() *Point {
+		return RandomPosition(radius, width, height)
+	})
+}
+
+// UniformPositionFrom is UniformPosition with the random points it picks from
+// made by random, so a caller with a seeded random source gets the same point
+// every time
+func UniformPositionFrom(points []*Point, random func() *Point) *Point {
 	var bestCandidate *Point
 	var maxDistance float64
 	var numberOfCandidates = 10
 	if len(points) == 0 {
-		return RandomPosition(radius)
+		return random()
 	}
 	for i := 0; i < numberOfCandidates; i++ {
 		var minDistance = math.MaxFloat64
-		candidate := RandomPosition(radius)
+		candidate := random()
 		for _, p := range points {
 			distance := GetDistance(candidate, p)
 			if distance < minDistance {
@@ -113,7 +120,7 @@ func UniformPosition(points []*Point, radius float64) *Point {
 			bestCandidate = candidate
 			maxDistance = minDistance
 		} else {
-			return RandomPosition(radius)
+			return random()
 		}
 	}
 	return bestCandidate
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// message is the envelope of every JSON websocket message
+type message struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// client is one synthetic player
+type client struct {
+	conn *websocket.Conn
+	name string
+	mu   sync.Mutex // gorilla allows only one writer at a time
+	ping int64      // unix nanos the unanswered ping was sent at, 0 if none
+	move int64      // unix nanos the oldest target no update has followed was sent at, 0 if none
+	s    *stats
+}
+
+// run connects a client called name to url and plays until ctx is done or
+// the server closes the connection
+func run(ctx context.Context, url, name string, s *stats) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		atomic.AddInt64(&s.failed, 1)
+		return
+	}
+	atomic.AddInt64(&s.connected, 1)
+	c := &client{conn: conn, name: name, s: s}
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		c.read()
+	}()
+	c.join()
+	c.play(ctx, closed)
+
+	c.mu.Lock()
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.mu.Unlock()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+	}
+	conn.Close()
+}
+
+// play sends targets, shots and pings at the configured rates
+func (c *client) play(ctx context.Context, closed chan struct{}) {
+	move, fire, ping := every(*moves), every(*fires), every(*pings)
+	defer move.Stop()
+	defer fire.Stop()
+	defer ping.Stop()
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for {
+		select {
+		case <-move.C:
+			atomic.CompareAndSwapInt64(&c.move, 0, time.Now().UnixNano())
+			c.send("0", map[string]float64{"x": r.Float64()*1000 - 500, "y": r.Float64()*1000 - 500})
+		case <-fire.C:
+			c.send("2", struct{}{})
+		case <-ping.C:
+			if atomic.CompareAndSwapInt64(&c.ping, 0, time.Now().UnixNano()) {
+				c.send("pingcheck", struct{}{})
+			}
+		case <-closed:
+			atomic.AddInt64(&c.s.dropped, 1)
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// read counts everything the server sends until the connection closes
+func (c *client) read() {
+	var last time.Time
+	for {
+		frame, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		atomic.AddInt64(&c.s.messages, 1)
+		atomic.AddInt64(&c.s.bytes, int64(len(data)))
+		m := message{}
+		if frame == websocket.BinaryMessage {
+			m.Type = "serverTellPlayerMove"
+		} else if err := json.Unmarshal(data, &m); err != nil {
+			continue
+		}
+		switch m.Type {
+		case "serverTellPlayerMove", "serverTellPlayerDelta":
+			now := time.Now()
+			if sent := atomic.SwapInt64(&c.move, 0); sent != 0 {
+				c.s.profile.Record(phaseMove, now.Sub(time.Unix(0, sent)))
+			}
+			if !last.IsZero() {
+				c.s.profile.Record(phaseUpdate, now.Sub(last))
+			}
+			last = now
+			atomic.AddInt64(&c.s.updates, 1)
+		case "pongcheck":
+			if sent := atomic.SwapInt64(&c.ping, 0); sent != 0 {
+				c.s.profile.Record(phasePing, time.Since(time.Unix(0, sent)))
+			}
+		case "RIP":
+			atomic.AddInt64(&c.s.deaths, 1)
+			c.send("respawn", struct{}{})
+			c.join()
+		case "kick":
+			return
+		}
+	}
+}
+
+// join sends the handshake that puts the player in the game
+func (c *client) join() {
+	c.send("gotit", map[string]interface{}{"name": c.name, "screenWidth": 1920, "screenHeight": 1080})
+}
+
+// send writes a message of type t carrying v
+func (c *client) send(t string, v interface{}) {
+	data, _ := json.Marshal(v)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	c.conn.WriteJSON(message{Type: t, Data: data})
+}
+
+// every returns a ticker firing rate times a second, or one that never fires
+// for rates of 0 or less
+func every(rate float64) *time.Ticker {
+	if rate <= 0 {
+		t := time.NewTicker(time.Hour)
+		t.Stop()
+		return t
+	}
+	return time.NewTicker(time.Duration(float64(time.Second) / rate))
+}
@@ -0,0 +1,115 @@
+// Command loadtest opens many synthetic player connections to a game server,
+// plays them like a person would and reports how quickly and how much state
+// the server sends back.
+//
+//	go run ./cmd/loadtest -addr localhost:3000 -clients 200 -duration 1m
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/krishamoud/game/app/common/profile"
+)
+
+// Phases the summary reports percentiles for. move is the latency of state
+// updates, the time from a client sending a target to the next update
+// arriving. update gap is how far apart updates arrive, the rate the server
+// sends at rather than a latency. ping is the round trip of a pingcheck.
+const (
+	phaseMove   = "move"
+	phaseUpdate = "update gap"
+	phasePing   = "ping"
+)
+
+// samples is how many of the latest samples of each phase are kept
+const samples = 100000
+
+var (
+	addr     = flag.String("addr", "localhost:3000", "host:port of the game server")
+	secure   = flag.Bool("tls", false, "connect with wss instead of ws")
+	room     = flag.String("room", "", "game to join, the server picks one when empty")
+	codec    = flag.String("codec", "json", "state update encoding, json or binary")
+	clients  = flag.Int("clients", 100, "number of connections to open")
+	ramp     = flag.Duration("ramp", 10*time.Second, "how long to spread opening the connections over")
+	duration = flag.Duration("duration", 30*time.Second, "how long to run once every connection is open")
+	moves    = flag.Float64("moves", 10, "target updates each client sends per second")
+	fires    = flag.Float64("fires", 1, "shots each client fires per second")
+	pings    = flag.Float64("pings", 1, "latency checks each client sends per second")
+)
+
+// stats is what every client adds to while the test runs
+type stats struct {
+	profile   *profile.Profiler
+	connected int64
+	failed    int64
+	dropped   int64
+	messages  int64
+	updates   int64
+	bytes     int64
+	deaths    int64
+}
+
+func main() {
+	flag.Parse()
+	u := url.URL{Scheme: "ws", Host: *addr, Path: "/api/v1/connect"}
+	if *secure {
+		u.Scheme = "wss"
+	}
+	q := url.Values{"type": {"player"}, "codec": {*codec}}
+	if *room != "" {
+		q.Set("room", *room)
+	}
+	u.RawQuery = q.Encode()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *ramp+*duration)
+	defer cancel()
+
+	s := &stats{profile: profile.New(0, samples, phaseMove, phaseUpdate, phasePing)}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < *clients && ctx.Err() == nil; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run(ctx, u.String(), fmt.Sprintf("load%d", i), s)
+		}(i)
+		select {
+		case <-time.After(*ramp / time.Duration(*clients)):
+		case <-ctx.Done():
+		}
+	}
+	wg.Wait()
+	s.print(os.Stdout, time.Since(start))
+}
+
+// print writes a summary of the test to w
+func (s *stats) print(w io.Writer, elapsed time.Duration) {
+	secs := elapsed.Seconds()
+	perClient := float64(atomic.LoadInt64(&s.connected))
+	if perClient == 0 {
+		perClient = 1
+	}
+	bytes := float64(atomic.LoadInt64(&s.bytes))
+	fmt.Fprintf(w, "target     %s, %d clients over %s, then %s\n", *addr, *clients, *ramp, *duration)
+	fmt.Fprintf(w, "clients    %d connected, %d failed, %d dropped early, %d deaths\n",
+		s.connected, s.failed, s.dropped, s.deaths)
+	fmt.Fprintf(w, "received   %d messages, %.1f KB in %s (%.1f KB/s, %.2f KB/s per client)\n",
+		s.messages, bytes/1024, elapsed.Round(time.Millisecond), bytes/1024/secs, bytes/1024/secs/perClient)
+	fmt.Fprintf(w, "updates    %d (%.1f/s per client)\n", s.updates, float64(s.updates)/secs/perClient)
+	fmt.Fprintf(w, "%-10s %8s %8s %8s %8s %8s %8s\n", "ms", "count", "mean", "p50", "p90", "p99", "max")
+	for _, p := range s.profile.Stats().Phases {
+		fmt.Fprintf(w, "%-10s %8d %8.1f %8.1f %8.1f %8.1f %8.1f\n", p.Name, p.Count, p.Mean, p.P50, p.P90, p.P99, p.Max)
+	}
+}
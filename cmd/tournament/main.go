@@ -0,0 +1,58 @@
+// Command tournament plays bot brains against each other in headless games
+// and ranks them by how much mass their bots end up with.
+//
+//	go run ./cmd/tournament -brains seeker,grazer -bots 5 -rounds 10
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/krishamoud/game/app/bundles/games"
+	"github.com/krishamoud/game/app/common/conf"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	configPath = flag.String("config", "", "path to the config file the maps are made from (default "+conf.DefaultPath+")")
+	brains     = flag.String("brains", strings.Join(games.Brains(), ","), "comma separated brains to play against each other")
+	bots       = flag.Int("bots", 5, "bots each brain plays in every round")
+	rounds     = flag.Int("rounds", 5, "number of games to play")
+	duration   = flag.Duration("duration", 2*time.Minute, "game time each round lasts")
+	seed       = flag.Int64("seed", 1, "seed of the first round, each round after adds one")
+)
+
+func main() {
+	flag.Parse()
+	cfg, err := conf.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := &games.Tournament{
+		Brains:   strings.Split(*brains, ","),
+		Bots:     *bots,
+		Rounds:   *rounds,
+		Duration: *duration,
+		Seed:     *seed,
+	}
+	start := time.Now()
+	scores, err := t.Run(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	report(os.Stdout, t, scores, time.Since(start))
+}
+
+// report writes the scores of t as a table to w
+func report(w io.Writer, t *games.Tournament, scores []games.Score, elapsed time.Duration) {
+	fmt.Fprintf(w, "%d rounds of %s with %d bots a brain, played in %s\n",
+		t.Rounds, t.Duration, t.Bots, elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "%-4s %-16s %10s %10s %8s\n", "rank", "brain", "mass", "peak", "deaths")
+	for i, s := range scores {
+		fmt.Fprintf(w, "%-4d %-16s %10.1f %10.1f %8d\n", i+1, s.Brain, s.Mass, s.Peak, s.Deaths)
+	}
+}
@@ -6,9 +6,15 @@ import (
 	"github.com/krishamoud/game/app/common/router"
 
 	// Common code
-	_ "github.com/krishamoud/game/app/common/conf"
+	"github.com/krishamoud/game/app/common/conf"
 
+	"context"
+	"flag"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -17,14 +23,76 @@ func main() {
 	// close the db connection when we're done
 	// defer db.MongoConn.Close()
 
-	// Start loop
-	go games.MainGame.GameInterval()
-	go games.MainGame.ClientManager.Start(games.MainGame)
+	configPath := flag.String("config", "", "path to the config file (default "+conf.DefaultPath+")")
+	flag.Parse()
+	cfg, err := conf.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	conf.Use(cfg)
+
+	// Start the default game, more are created as players join
+	if _, err := games.Manager.Create(""); err != nil {
+		log.Fatal(err)
+	}
+
+	c := conf.AppConf
 
 	// Handle all requests with gorilla/mux
 	http.Handle("/", router.Router())
+	server := &http.Server{
+		Addr:         c.Addr(),
+		ReadTimeout:  time.Duration(c.ReadTimeout) * time.Millisecond,
+		WriteTimeout: time.Duration(c.ServerWriteTimeout) * time.Millisecond,
+		IdleTimeout:  time.Duration(c.IdleTimeout) * time.Millisecond,
+	}
+
+	// Listen on the configured address, over TLS when a cert and key are set
+	go func() {
+		var err error
+		if c.TLS() {
+			log.Println("Server listening on https://" + server.Addr)
+			err = server.ListenAndServeTLS(c.TLSCert, c.TLSKey)
+		} else {
+			log.Println("Server listening on http://" + server.Addr)
+			err = server.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// Wait for SIGINT or SIGTERM, then give players a countdown and close
+	// everything down before the deadline
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Apply live settings from the config file whenever it changes
+	if c.ConfigPollInterval > 0 {
+		path := *configPath
+		if path == "" {
+			path = conf.DefaultPath
+		}
+		go conf.Watch(ctx, path, time.Duration(c.ConfigPollInterval)*time.Millisecond, func(cfg *conf.Configuration, err error) {
+			if err == nil {
+				_, _, err = games.Manager.Reload(cfg, "file "+path)
+			}
+			if err != nil {
+				log.WithField("error", err).Error("Could not reload " + path)
+			}
+		})
+	}
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down")
 
-	// Listen on port 9090
-	log.Println("Server listening on port 9090")
-	log.Fatal(http.ListenAndServe(":9090", nil))
+	deadline, cancel := context.WithTimeout(context.Background(), time.Duration(c.ShutdownTimeout)*time.Millisecond)
+	defer cancel()
+	if err := games.Manager.Shutdown(deadline, time.Duration(c.ShutdownCountdown)*time.Millisecond); err != nil {
+		log.WithField("error", err).Error("Games did not stop in time")
+	}
+	if err := server.Shutdown(deadline); err != nil {
+		log.WithField("error", err).Error("Server did not stop in time")
+	}
+	log.Println("Server stopped")
 }